@@ -0,0 +1,511 @@
+package goflyway
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dimchansky/utfbom"
+)
+
+// DDLObject 表示一条 DDL 语句创建、修改或删除的数据库对象
+type DDLObject struct {
+	Action string // CREATE, ALTER 或 DROP
+	Kind   string // TABLE, INDEX, VIEW, FUNCTION 等
+	Name   string // 对象名，带 schema 时为 schema.name，已去掉引号
+}
+
+// ddlObjectKinds 可识别的对象类型
+var ddlObjectKinds = map[string]bool{
+	"TABLE":     true,
+	"INDEX":     true,
+	"VIEW":      true,
+	"FUNCTION":  true,
+	"PROCEDURE": true,
+	"SEQUENCE":  true,
+	"TRIGGER":   true,
+	"SCHEMA":    true,
+	"TYPE":      true,
+}
+
+// ddlModifiers 出现在 CREATE/ALTER/DROP 和对象类型之间、可以忽略的修饰词
+var ddlModifiers = map[string]bool{
+	"OR":             true,
+	"REPLACE":        true,
+	"UNIQUE":         true,
+	"TEMP":           true,
+	"TEMPORARY":      true,
+	"GLOBAL":         true,
+	"LOCAL":          true,
+	"UNLOGGED":       true,
+	"MATERIALIZED":   true,
+	"EDITIONABLE":    true,
+	"NONEDITIONABLE": true,
+	"CLUSTERED":      true,
+	"NONCLUSTERED":   true,
+}
+
+// ExtractDDLObjects 分析 SQL 脚本，返回其中每条 DDL 语句涉及的对象
+func ExtractDDLObjects(in io.Reader) ([]DDLObject, error) {
+	return extractDDLObjects(in, &Config{})
+}
+
+func extractDDLObjects(in io.Reader, cfg *Config) ([]DDLObject, error) {
+	statements, err := SplitWithConfig(in, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []DDLObject
+	for _, stmt := range statements {
+		tokens, err := significantTokens(stmt)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, parseDDLHead(tokens)...)
+	}
+	return objects, nil
+}
+
+// AnalyzeDDL 分析文件系统中所有的 Flyway 迁移文件，按文件路径返回各自涉及的对象
+func AnalyzeDDL(fsys fs.FS) (map[string][]DDLObject, error) {
+	return AnalyzeDDLWithConfig(fsys, &Config{})
+}
+
+// AnalyzeDDLWithConfig 与 AnalyzeDDL 相同，按 cfg 中的前缀、分隔符和扩展名识别迁移文件
+func AnalyzeDDLWithConfig(fsys fs.FS, cfg *Config) (map[string][]DDLObject, error) {
+	results := map[string][]DDLObject{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isFlywayFilename(path, cfg) {
+			return nil
+		}
+
+		file, err := fsys.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer file.Close()
+
+		objects, err := extractDDLObjects(utfbom.SkipOnly(file), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to analyze %s: %w", path, err)
+		}
+		results[path] = objects
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ClassifyStatement 返回语句的类型，如 "CREATE TABLE"、"CREATE FUNCTION"、"INSERT"、"UPDATE"，
+// 语句为空或只有注释时返回空字符串
+func ClassifyStatement(stmt string) (string, error) {
+	tokens, err := significantTokens(stmt)
+	if err != nil {
+		return "", err
+	}
+	return classifyTokens(tokens), nil
+}
+
+func classifyTokens(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	// AS/DO 开始的 $$ 代码块会和关键字合成一个 token
+	action := strings.ToUpper(strings.Fields(tokens[0])[0])
+	if action != "CREATE" && action != "ALTER" && action != "DROP" {
+		return action
+	}
+
+	pos := 1
+	for pos < len(tokens) && ddlModifiers[strings.ToUpper(tokens[pos])] {
+		pos++
+	}
+	if pos >= len(tokens) || !isIdentifierToken(tokens[pos]) {
+		return action
+	}
+	return action + " " + strings.ToUpper(tokens[pos])
+}
+
+func isIdentifierToken(token string) bool {
+	for _, r := range token {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return token != ""
+}
+
+// significantTokens 返回语句中除空白和注释以外的 token
+func significantTokens(stmt string) ([]string, error) {
+	var tokens []string
+	tokenizer := NewTokenizerWithOptions(strings.NewReader(stmt), TokenizerOptions{CommentTokens: true, ExecutableComments: true})
+	for {
+		token, err := tokenizer.NextToken()
+		if value := strings.TrimSpace(token.Value); value != "" && token.Type != TokenComment {
+			tokens = append(tokens, value)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return tokens, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// parseDDLHead 解析语句开头的 CREATE/ALTER/DROP <kind> <name> 部分
+func parseDDLHead(tokens []string) []DDLObject {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	action := strings.ToUpper(tokens[0])
+	if action != "CREATE" && action != "ALTER" && action != "DROP" {
+		return nil
+	}
+
+	pos := 1
+	for pos < len(tokens) && ddlModifiers[strings.ToUpper(tokens[pos])] {
+		pos++
+	}
+	if pos >= len(tokens) || !ddlObjectKinds[strings.ToUpper(tokens[pos])] {
+		return nil
+	}
+	kind := strings.ToUpper(tokens[pos])
+	pos++
+
+	pos = skipWords(tokens, pos, "CONCURRENTLY")
+	pos = skipWords(tokens, pos, "IF", "NOT", "EXISTS")
+	pos = skipWords(tokens, pos, "IF", "EXISTS")
+
+	var objects []DDLObject
+	for {
+		name, next := readQualifiedName(tokens, pos)
+		if name == "" {
+			break
+		}
+		objects = append(objects, DDLObject{Action: action, Kind: kind, Name: name})
+
+		// 只有 DROP 支持逗号分隔的多个对象
+		if action != "DROP" || next >= len(tokens) || tokens[next] != "," {
+			break
+		}
+		pos = next + 1
+	}
+	return objects
+}
+
+// skipWords 当 tokens[pos:] 依次匹配 words 时跳过它们
+func skipWords(tokens []string, pos int, words ...string) int {
+	for i, word := range words {
+		if pos+i >= len(tokens) || !strings.EqualFold(tokens[pos+i], word) {
+			return pos
+		}
+	}
+	return pos + len(words)
+}
+
+// readQualifiedName 读取 name 或 schema.name 形式的（可能带引号的）标识符
+func readQualifiedName(tokens []string, pos int) (string, int) {
+	var parts []string
+	for pos < len(tokens) {
+		part, ok := unquoteIdentifier(tokens[pos])
+		if !ok {
+			break
+		}
+		parts = append(parts, part)
+		pos++
+
+		if pos+1 >= len(tokens) || tokens[pos] != "." {
+			break
+		}
+		pos++
+	}
+
+	// CREATE INDEX ON t(...) 这类没有名字的情况
+	if len(parts) == 1 && strings.EqualFold(parts[0], "ON") {
+		return "", pos
+	}
+	return strings.Join(parts, "."), pos
+}
+
+// unquoteIdentifier 去掉标识符两侧的引号，不是标识符时返回 false
+func unquoteIdentifier(token string) (string, bool) {
+	if len(token) >= 2 && token[0] == '"' && token[len(token)-1] == '"' {
+		return strings.ReplaceAll(token[1:len(token)-1], `""`, `"`), true
+	}
+	if !isIdentifierToken(token) {
+		return "", false
+	}
+	return token, true
+}
+
+// ddlActions 和 dmlActions 为 ClassifyStatement 返回值的第一个单词
+var (
+	ddlActions = map[string]bool{"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "RENAME": true}
+	dmlActions = map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true, "MERGE": true}
+)
+
+// MixesDDLAndDML 检查 SQL 脚本中是否同时包含 DDL 和 DML 语句
+func MixesDDLAndDML(in io.Reader) (bool, error) {
+	statements, err := Split(in)
+	if err != nil {
+		return false, err
+	}
+
+	var hasDDL, hasDML bool
+	for _, stmt := range statements {
+		kind, err := ClassifyStatement(stmt)
+		if err != nil {
+			return false, err
+		}
+		action, _, _ := strings.Cut(kind, " ")
+		hasDDL = hasDDL || ddlActions[action]
+		hasDML = hasDML || dmlActions[action]
+	}
+	return hasDDL && hasDML, nil
+}
+
+// checkMixedDDL 按 cfg.MixedDDLCheck 检查 MySQL 迁移是否混合了 DDL 和 DML
+func checkMixedDDL(path, text string, cfg *Config) error {
+	if cfg.MixedDDLCheck == "" || DriverFamily(cfg.DBDriver) != "mysql" {
+		return nil
+	}
+
+	mixed, err := MixesDDLAndDML(strings.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("failed to analyze %s: %w", path, err)
+	}
+	if !mixed {
+		return nil
+	}
+
+	if cfg.MixedDDLCheck == StrictnessError {
+		return fmt.Errorf("%s mixes DDL and DML statements, which cannot be rolled back on MySQL", path)
+	}
+	cfg.logger().Warnf("%s mixes DDL and DML statements, a failure may leave MySQL half-migrated", path)
+	return nil
+}
+
+// migrationObjects 一个迁移文件创建和引用的对象，对象名已转为小写并去掉 schema
+type migrationObjects struct {
+	source     string
+	version    int64
+	created    []string
+	referenced []string
+}
+
+// dependencyKinds 可能被其它迁移引用的对象类型
+var dependencyKinds = map[string]bool{
+	"TABLE":    true,
+	"VIEW":     true,
+	"FUNCTION": true,
+	"SEQUENCE": true,
+	"SCHEMA":   true,
+	"TYPE":     true,
+}
+
+// collectMigrationObjects 分析迁移文件中 CREATE 语句创建的对象，以及其它语句中出现的标识符。
+// 在同一个文件中先创建后引用的对象不计入引用
+func collectMigrationObjects(source string, version int64, text string) (migrationObjects, error) {
+	objects := migrationObjects{source: source, version: version}
+
+	statements, err := Split(strings.NewReader(text))
+	if err != nil {
+		return objects, err
+	}
+
+	created := map[string]bool{}
+	referenced := map[string]bool{}
+	for _, stmt := range statements {
+		tokens, err := significantTokens(stmt)
+		if err != nil {
+			return objects, err
+		}
+
+		var own []string
+		for _, object := range parseDDLHead(tokens) {
+			if object.Action == "CREATE" && dependencyKinds[object.Kind] {
+				own = append(own, dependencyName(object.Name))
+			}
+		}
+
+		for pos := 0; pos < len(tokens); {
+			name, next := readQualifiedName(tokens, pos)
+			if next == pos {
+				next++
+			}
+			pos = next
+			if name == "" {
+				continue
+			}
+			name = dependencyName(name)
+			if !created[name] && !sqlKeywords[strings.ToUpper(name)] && !containsString(own, name) {
+				referenced[name] = true
+			}
+		}
+
+		for _, name := range own {
+			if !created[name] {
+				created[name] = true
+				objects.created = append(objects.created, name)
+			}
+		}
+	}
+
+	for name := range referenced {
+		objects.referenced = append(objects.referenced, name)
+	}
+	sort.Strings(objects.referenced)
+	return objects, nil
+}
+
+// dependencyName 返回对象名去掉 schema 后的小写形式
+func dependencyName(name string) string {
+	if idx := strings.LastIndexByte(name, '.'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return strings.ToLower(name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDependencyOrder 按 goose 版本排序后，找出引用了排在后面的迁移才创建的对象的迁移
+func checkDependencyOrder(migrations []migrationObjects) []string {
+	sorted := append([]migrationObjects(nil), migrations...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].version < sorted[j].version })
+
+	creators := map[string]int{} // 对象名 -> 最早创建它的迁移在 sorted 中的位置
+	for idx, m := range sorted {
+		for _, name := range m.created {
+			if _, ok := creators[name]; !ok {
+				creators[name] = idx
+			}
+		}
+	}
+
+	var warnings []string
+	for idx, m := range sorted {
+		for _, name := range m.referenced {
+			if creator, ok := creators[name]; ok && creator > idx {
+				warnings = append(warnings, fmt.Sprintf("%s references %s, which is created by %s that runs later in goose order",
+					m.source, name, sorted[creator].source))
+			}
+		}
+	}
+	return warnings
+}
+
+// DialectIssue 表示迁移中使用了某个数据库不支持的语法
+type DialectIssue struct {
+	Source  string `json:"source"`  // Flyway 文件路径
+	Dialect string `json:"dialect"` // 不支持该语法的数据库类型(见 DriverFamily)
+	Feature string `json:"feature"` // 语法，如 "AUTO_INCREMENT"、"backtick quoting"
+	Line    int    `json:"line"`    // 第一次出现的行号，从 1 开始
+}
+
+// portabilityDialects CheckPortability 支持检查的数据库类型
+var portabilityDialects = map[string]bool{"mysql": true, "postgres": true, "sqlite3": true}
+
+// unportableKeywords 只有部分数据库支持的关键字和类型 -> 不支持它的数据库类型。只是启发式检查，
+// SQLite 接受任意的类型名，因此不检查类型
+var unportableKeywords = map[string][]string{
+	"AUTO_INCREMENT": {"postgres", "sqlite3"},
+	"AUTOINCREMENT":  {"mysql", "postgres"},
+	"UNSIGNED":       {"postgres"},
+	"TINYINT":        {"postgres"},
+	"MEDIUMINT":      {"postgres"},
+	"DATETIME":       {"postgres"},
+	"SERIAL":         {"sqlite3"},
+	"BIGSERIAL":      {"mysql", "sqlite3"},
+	"SMALLSERIAL":    {"mysql", "sqlite3"},
+	"BYTEA":          {"mysql"},
+	"TIMESTAMPTZ":    {"mysql"},
+	"JSONB":          {"mysql"},
+	"ILIKE":          {"mysql", "sqlite3"},
+}
+
+// CheckPortability 检查 SQL 脚本中是否使用了 dialects 中某些数据库不支持的语法，
+// 每种语法在每个数据库上只报告第一次出现的位置。字符串和注释中的内容不检查
+func CheckPortability(in io.Reader, dialects []string) ([]DialectIssue, error) {
+	for _, dialect := range dialects {
+		if !portabilityDialects[DriverFamily(dialect)] {
+			return nil, fmt.Errorf("unsupported dialect %q for portability check", dialect)
+		}
+	}
+
+	var issues []DialectIssue
+	reported := map[string]bool{}
+	report := func(feature string, line int, unsupported ...string) {
+		for _, dialect := range dialects {
+			family := DriverFamily(dialect)
+			if !containsString(unsupported, family) || reported[family+" "+feature] {
+				continue
+			}
+			reported[family+" "+feature] = true
+			issues = append(issues, DialectIssue{Dialect: family, Feature: feature, Line: line})
+		}
+	}
+
+	tokenizer := NewTokenizer(in)
+	line, previous := 1, ""
+	for {
+		token, err := tokenizer.NextToken()
+		if token.Type == TokenText || token.Type == TokenBegin || token.Type == TokenEnd {
+			value := token.Value
+			switch {
+			case strings.HasPrefix(value, "`"):
+				report("backtick quoting", line, "postgres")
+			case isDollarQuotedToken(value):
+				report("dollar quoting", line, "mysql", "sqlite3")
+			case value == ":" && previous == ":":
+				report(":: cast", line, "mysql", "sqlite3")
+			default:
+				if word := strings.ToUpper(value); unportableKeywords[word] != nil {
+					report(word, line, unportableKeywords[word]...)
+				}
+			}
+		}
+		line += strings.Count(token.Value, "\n")
+		previous = token.Value
+		if err != nil {
+			if err == io.EOF {
+				return issues, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// isDollarQuotedToken 判断 token 是否为 $tag$ ... $tag$ 形式的字符串，AS/DO 的 token 中会带有后面的 $$ 代码块
+func isDollarQuotedToken(value string) bool {
+	head, rest, ok := strings.Cut(value, "$")
+	if !ok {
+		return false
+	}
+	if head = strings.TrimSpace(head); head != "" && !strings.EqualFold(head, "AS") && !strings.EqualFold(head, "DO") {
+		return false
+	}
+	tag, _, ok := strings.Cut(rest, "$")
+	if !ok {
+		return false
+	}
+	return tag == "" || (!unicode.IsDigit(rune(tag[0])) && isIdentifierToken(tag))
+}
@@ -0,0 +1,257 @@
+package goflyway
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExtractDDLObjects(t *testing.T) {
+	input := `CREATE TABLE users (id INT PRIMARY KEY, name TEXT);
+CREATE TABLE IF NOT EXISTS public.orders (id INT);
+CREATE TABLE "My Schema"."Audit Log" (id INT);
+CREATE UNIQUE INDEX idx_users_name ON users (name);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS app.idx_orders_id ON app.orders (id);
+CREATE OR REPLACE FUNCTION public.touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- CREATE TABLE commented_out (id INT);
+ALTER TABLE users ADD COLUMN email TEXT;
+DROP VIEW IF EXISTS v_old, v_older;
+INSERT INTO users VALUES (1, 'CREATE TABLE not_a_table');
+`
+	expected := []DDLObject{
+		{Action: "CREATE", Kind: "TABLE", Name: "users"},
+		{Action: "CREATE", Kind: "TABLE", Name: "public.orders"},
+		{Action: "CREATE", Kind: "TABLE", Name: "My Schema.Audit Log"},
+		{Action: "CREATE", Kind: "INDEX", Name: "idx_users_name"},
+		{Action: "CREATE", Kind: "INDEX", Name: "app.idx_orders_id"},
+		{Action: "CREATE", Kind: "FUNCTION", Name: "public.touch"},
+		{Action: "ALTER", Kind: "TABLE", Name: "users"},
+		{Action: "DROP", Kind: "VIEW", Name: "v_old"},
+		{Action: "DROP", Kind: "VIEW", Name: "v_older"},
+	}
+
+	objects, err := ExtractDDLObjects(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ExtractDDLObjects() error = %v", err)
+	}
+	if !reflect.DeepEqual(objects, expected) {
+		t.Errorf("ExtractDDLObjects() mismatch:\nExpected: %v\nGot:      %v", expected, objects)
+	}
+}
+
+func TestAnalyzeDDL(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1__init.sql":     {Data: []byte("CREATE TABLE a (id INT);\nCREATE INDEX ON a (id);")},
+		"V2__views.sql":    {Data: []byte("CREATE VIEW v AS SELECT * FROM a;")},
+		"README.md":        {Data: []byte("CREATE TABLE ignored (id INT);")},
+		"sub/V3__drop.sql": {Data: []byte("DROP TABLE a;")},
+	}
+
+	results, err := AnalyzeDDL(fsys)
+	if err != nil {
+		t.Fatalf("AnalyzeDDL() error = %v", err)
+	}
+
+	expected := map[string][]DDLObject{
+		"V1__init.sql":     {{Action: "CREATE", Kind: "TABLE", Name: "a"}},
+		"V2__views.sql":    {{Action: "CREATE", Kind: "VIEW", Name: "v"}},
+		"sub/V3__drop.sql": {{Action: "DROP", Kind: "TABLE", Name: "a"}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("AnalyzeDDL() mismatch:\nExpected: %v\nGot:      %v", expected, results)
+	}
+}
+
+func TestAnalyzeDDLWithConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"M1-init.ddl":  {Data: []byte("CREATE TABLE a (id INT);")},
+		"V2__init.sql": {Data: []byte("CREATE TABLE ignored (id INT);")},
+	}
+
+	results, err := AnalyzeDDLWithConfig(fsys, &Config{Prefix: "M", Separator: "-", Extensions: []string{".ddl"}})
+	if err != nil {
+		t.Fatalf("AnalyzeDDLWithConfig() error = %v", err)
+	}
+
+	expected := map[string][]DDLObject{
+		"M1-init.ddl": {{Action: "CREATE", Kind: "TABLE", Name: "a"}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("AnalyzeDDLWithConfig() mismatch:\nExpected: %v\nGot:      %v", expected, results)
+	}
+}
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		expected string
+	}{
+		{"CREATE TABLE t (id INT);", "CREATE TABLE"},
+		{"-- comment\ncreate or replace function f() returns void as $$ begin end; $$ language plpgsql;", "CREATE FUNCTION"},
+		{"CREATE UNIQUE INDEX i ON t (id);", "CREATE INDEX"},
+		{"ALTER TABLE t ADD COLUMN c INT;", "ALTER TABLE"},
+		{"insert into t values (1);", "INSERT"},
+		{"DO $$ BEGIN PERFORM 1; END $$;", "DO"},
+		{"-- only comment", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			kind, err := ClassifyStatement(tt.stmt)
+			if err != nil {
+				t.Fatalf("ClassifyStatement() error = %v", err)
+			}
+			if kind != tt.expected {
+				t.Errorf("ClassifyStatement() = %q, want %q", kind, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckMixedDDL(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1__mixed.sql": {Data: []byte("CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1);\n")},
+		"V2__ddl.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN name TEXT;\nCREATE INDEX idx ON t (name);\n")},
+		"V3__dml.sql":   {Data: []byte("UPDATE t SET name = 'a';\nDELETE FROM t WHERE id = 2;\n")},
+	}
+
+	tests := []struct {
+		name        string
+		driver      string
+		strictness  string
+		expectWarn  bool
+		expectError bool
+	}{
+		{name: "MySQL 警告", driver: "mysql", strictness: StrictnessWarn, expectWarn: true},
+		{name: "MySQL 错误", driver: "mysql", strictness: StrictnessError, expectError: true},
+		{name: "Postgres 不检查", driver: "postgres", strictness: StrictnessWarn},
+		{name: "未开启", driver: "mysql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			SetLogger(NewStdLogger(log.New(&logs, "", 0)))
+			defer SetLogger(nil)
+
+			cfg := &Config{BaseYear: "2000", DBDriver: tt.driver, MixedDDLCheck: tt.strictness}
+			err := processFS(fsys, t.TempDir(), cfg)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("processFS() error = %v, expectError %v", err, tt.expectError)
+			}
+			if tt.expectError && !strings.Contains(err.Error(), "V1__mixed.sql") {
+				t.Errorf("error should name the mixed file: %v", err)
+			}
+
+			warned := strings.Contains(logs.String(), "mixes DDL and DML")
+			if warned != tt.expectWarn {
+				t.Errorf("warned = %v, want %v (logs: %q)", warned, tt.expectWarn, logs.String())
+			}
+			if warned && strings.Count(logs.String(), "mixes DDL and DML") != 1 {
+				t.Errorf("only V1__mixed.sql should be flagged: %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestDependencyCheck(t *testing.T) {
+	// 14 位的版本号直接作为 goose 版本，排到了 V1.2 生成的 20000102000000 之前，
+	// 但它引用的 users 表由 V1.2 创建
+	fsys := fstest.MapFS{
+		"V1.2__create_users.sql":             {Data: []byte("CREATE TABLE app.users (id INT PRIMARY KEY);\n")},
+		"V19990101000000__create_orders.sql": {Data: []byte("CREATE TABLE orders (id INT, user_id INT REFERENCES app.users (id));\nCREATE INDEX idx_orders_user ON orders (user_id);\n")},
+		"V1.3__create_items.sql":             {Data: []byte("CREATE TABLE items (id INT, order_id INT REFERENCES orders (id));\n")},
+	}
+
+	var logs bytes.Buffer
+	SetLogger(NewStdLogger(log.New(&logs, "", 0)))
+	defer SetLogger(nil)
+
+	cfg := &Config{BaseYear: "2000", DependencyCheck: true}
+	if err := processFS(fsys, t.TempDir(), cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	want := "WARNING: V19990101000000__create_orders.sql references users, which is created by V1.2__create_users.sql that runs later in goose order"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("log missing %q, got:\n%s", want, logs.String())
+	}
+	if strings.Count(logs.String(), "runs later in goose order") != 1 {
+		t.Errorf("only the reordered migration should be flagged, got:\n%s", logs.String())
+	}
+
+	// 未开启时不检查
+	logs.Reset()
+	if err := processFS(fsys, t.TempDir(), &Config{BaseYear: "2000"}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if strings.Contains(logs.String(), "runs later in goose order") {
+		t.Errorf("unexpected warning without DependencyCheck:\n%s", logs.String())
+	}
+}
+
+func TestCheckPortability(t *testing.T) {
+	input := "CREATE TABLE `users` (\n" +
+		"  id INT AUTO_INCREMENT PRIMARY KEY,\n" +
+		"  note TEXT DEFAULT 'SERIAL' -- BIGSERIAL\n" +
+		");\n" +
+		"CREATE TABLE orders (id BIGSERIAL, total TEXT);\n" +
+		"SELECT total::int FROM orders;\n" +
+		"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql;\n"
+
+	issues, err := CheckPortability(strings.NewReader(input), []string{"mysql", "pgx"})
+	if err != nil {
+		t.Fatalf("CheckPortability() error = %v", err)
+	}
+	want := []DialectIssue{
+		{Dialect: "postgres", Feature: "backtick quoting", Line: 1},
+		{Dialect: "postgres", Feature: "AUTO_INCREMENT", Line: 2},
+		{Dialect: "mysql", Feature: "BIGSERIAL", Line: 5},
+		{Dialect: "mysql", Feature: ":: cast", Line: 6},
+		{Dialect: "mysql", Feature: "dollar quoting", Line: 7},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("CheckPortability() = %+v, want %+v", issues, want)
+	}
+
+	if _, err := CheckPortability(strings.NewReader(input), []string{"oracle"}); err == nil {
+		t.Error("CheckPortability() expected error for unsupported dialect")
+	}
+}
+
+func TestProcessFS_Dialects(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1__create_users.sql": {Data: []byte("CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY);\n")},
+		"V2__create_items.sql": {Data: []byte("CREATE TABLE items (id INT PRIMARY KEY);\n")},
+	}
+
+	var logs bytes.Buffer
+	report, err := processFSWithReport(fsys, t.TempDir(), &Config{
+		BaseYear: "2000",
+		Dialects: []string{"mysql", "postgres"},
+		Logger:   NewStdLogger(log.New(&logs, "", 0)),
+	})
+	if err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	want := []DialectIssue{{Source: "V1__create_users.sql", Dialect: "postgres", Feature: "AUTO_INCREMENT", Line: 1}}
+	if !reflect.DeepEqual(report.DialectIssues, want) {
+		t.Errorf("DialectIssues = %+v, want %+v", report.DialectIssues, want)
+	}
+	if msg := "WARNING: V1__create_users.sql: AUTO_INCREMENT (line 1) is not supported by postgres"; !strings.Contains(logs.String(), msg) {
+		t.Errorf("log missing %q, got:\n%s", msg, logs.String())
+	}
+
+	if _, err := processFSWithReport(fsys, t.TempDir(), &Config{BaseYear: "2000", Dialects: []string{"db2"}}); err == nil {
+		t.Error("processFS() expected error for unsupported dialect")
+	}
+}
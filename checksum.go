@@ -0,0 +1,225 @@
+package goflyway
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GooseChecksumsFile 是输出目录中记录生成文件哈希的文件名
+const GooseChecksumsFile = ".goose-checksums"
+
+// contentChecksum 计算文件内容的哈希，格式为 "sha256:<hex>"
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// flywayChecksum 按 Flyway 的算法计算脚本的 checksum：去掉 BOM 后按行(不含换行符)计算 CRC32，
+// 结果为有符号的 32 位整数，与 flyway_schema_history.checksum 相同
+func flywayChecksum(content string) int32 {
+	content = strings.TrimPrefix(content, "\uFEFF")
+	hash := crc32.NewIEEE()
+	for content != "" {
+		end := strings.IndexAny(content, "\r\n")
+		if end < 0 {
+			hash.Write([]byte(content))
+			break
+		}
+		hash.Write([]byte(content[:end]))
+		if strings.HasPrefix(content[end:], "\r\n") {
+			end++
+		}
+		content = content[end+1:]
+	}
+	return int32(hash.Sum32())
+}
+
+// isUpToDate 检查 path 是否已存在且内容哈希与 content 相同
+func isUpToDate(path, content string) bool {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return contentChecksum(string(existing)) == contentChecksum(content)
+}
+
+// writeChecksumFile 按文件名排序写出 .goose-checksums，每行格式为 "<文件名> <哈希>"
+func writeChecksumFile(outputDir string, checksums map[string]string) error {
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteString(" ")
+		sb.WriteString(checksums[name])
+		sb.WriteString("\n")
+	}
+
+	outputPath := filepath.Join(outputDir, GooseChecksumsFile)
+	if err := os.WriteFile(outputPath, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// ReadChecksumFile 读取输出目录中的 .goose-checksums，返回 文件名 -> 哈希
+func ReadChecksumFile(outputDir string) (map[string]string, error) {
+	file, err := os.Open(filepath.Join(outputDir, GooseChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line: %q", line)
+		}
+		checksums[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// VerifyChecksums 重新计算输出目录中各文件的哈希，返回与 .goose-checksums 不一致的文件名
+func VerifyChecksums(outputDir string) ([]string, error) {
+	checksums, err := ReadChecksumFile(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatched []string
+	for name, expected := range checksums {
+		content, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil || contentChecksum(string(content)) != expected {
+			mismatched = append(mismatched, name)
+		}
+	}
+	sort.Strings(mismatched)
+	return mismatched, nil
+}
+
+// DiffConversions 逐个文件比较两个输出目录，返回差异的说明，为空表示两次转换的结果相同。
+// 匹配 ignore 中任一正则的行不参与比较，用于排除生成时间之类每次转换都会变化的内容
+func DiffConversions(dirA, dirB string, ignore ...*regexp.Regexp) ([]string, error) {
+	filesA, err := listOutputFiles(dirA)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := listOutputFiles(dirB)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(filesA)+len(filesB))
+	for name := range filesA {
+		names = append(names, name)
+	}
+	for name := range filesB {
+		if _, ok := filesA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, name := range names {
+		_, inA := filesA[name]
+		_, inB := filesB[name]
+		switch {
+		case !inB:
+			diffs = append(diffs, fmt.Sprintf("%s: only in %s", name, dirA))
+		case !inA:
+			diffs = append(diffs, fmt.Sprintf("%s: only in %s", name, dirB))
+		default:
+			a, err := os.ReadFile(filepath.Join(dirA, name))
+			if err != nil {
+				return nil, err
+			}
+			b, err := os.ReadFile(filepath.Join(dirB, name))
+			if err != nil {
+				return nil, err
+			}
+			if line := firstDiffLine(string(a), string(b), ignore); line > 0 {
+				diffs = append(diffs, fmt.Sprintf("%s: differs at line %d", name, line))
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// listOutputFiles 返回 dir 下所有文件相对于 dir 的路径
+func listOutputFiles(dir string) (map[string]struct{}, error) {
+	files := map[string]struct{}{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// firstDiffLine 跳过匹配 ignore 的行后逐行比较，返回第一处不同在 a 中的行号，相同时返回 0
+func firstDiffLine(a, b string, ignore []*regexp.Regexp) int {
+	linesA := strings.Split(a, "\n")
+	linesB := strings.Split(b, "\n")
+	i, j := 0, 0
+	for {
+		for i < len(linesA) && isIgnoredDiffLine(linesA[i], ignore) {
+			i++
+		}
+		for j < len(linesB) && isIgnoredDiffLine(linesB[j], ignore) {
+			j++
+		}
+		if i >= len(linesA) || j >= len(linesB) {
+			if i >= len(linesA) && j >= len(linesB) {
+				return 0
+			}
+			return i + 1
+		}
+		if linesA[i] != linesB[j] {
+			return i + 1
+		}
+		i++
+		j++
+	}
+}
+
+func isIgnoredDiffLine(line string, ignore []*regexp.Regexp) bool {
+	for _, re := range ignore {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package goflyway
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmitChecksums(t *testing.T) {
+	outputDir := t.TempDir()
+	cfg := &Config{BaseYear: "2000", EmitChecksums: true}
+	if err := processFS(os.DirFS("testdata"), outputDir, cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	checksums, err := ReadChecksumFile(outputDir)
+	if err != nil {
+		t.Fatalf("ReadChecksumFile() error = %v", err)
+	}
+
+	expected := map[string]string{}
+	for _, name := range []string{
+		"20000101000000_first_migration.sql",
+		"20000102000003_second_migration.sql",
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		expected[name] = contentChecksum(string(content))
+	}
+	if !reflect.DeepEqual(checksums, expected) {
+		t.Errorf("checksums mismatch:\nExpected: %v\nGot:      %v", expected, checksums)
+	}
+
+	mismatched, err := VerifyChecksums(outputDir)
+	if err != nil {
+		t.Fatalf("VerifyChecksums() error = %v", err)
+	}
+	if len(mismatched) != 0 {
+		t.Errorf("unexpected mismatches: %v", mismatched)
+	}
+
+	// 修改已生成的文件后应能检测到漂移
+	drifted := filepath.Join(outputDir, "20000101000000_first_migration.sql")
+	if err := os.WriteFile(drifted, []byte("-- +goose Up\nSELECT 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mismatched, err = VerifyChecksums(outputDir)
+	if err != nil {
+		t.Fatalf("VerifyChecksums() error = %v", err)
+	}
+	if !reflect.DeepEqual(mismatched, []string{"20000101000000_first_migration.sql"}) {
+		t.Errorf("drift not detected: %v", mismatched)
+	}
+}
+
+func TestProcessFS_Resume(t *testing.T) {
+	testFS := fstest.MapFS{
+		"V1__first.sql":  {Data: []byte("CREATE TABLE a (id INT);\n")},
+		"V2__second.sql": {Data: []byte("CREATE TABLE b (id INT);\n")},
+		"V3__third.sql":  {Data: []byte("CREATE TABLE c (id INT);\n")},
+	}
+
+	outputDir := t.TempDir()
+	cfg := &Config{BaseYear: "2000", Resume: true}
+	if err := processFS(testFS, outputDir, cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	// 模拟中断：第一个文件已完成，第二个文件的源文件发生了变化，第三个文件还没有生成
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	first := filepath.Join(outputDir, "20000101000000_first.sql")
+	if err := os.Chtimes(first, old, old); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(outputDir, "20000201000000_second.sql")
+	if err := os.Chtimes(second, old, old); err != nil {
+		t.Fatal(err)
+	}
+	testFS["V2__second.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (id BIGINT);\n")}
+	if err := os.Remove(filepath.Join(outputDir, "20000301000000_third.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := processFSWithReport(testFS, outputDir, cfg)
+	if err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if !reflect.DeepEqual(report.Resumed, []string{"20000101000000_first.sql"}) {
+		t.Errorf("Resumed = %v, want [20000101000000_first.sql]", report.Resumed)
+	}
+
+	if fi, err := os.Stat(first); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("up-to-date output should not be rewritten")
+	}
+	if fi, err := os.Stat(second); err != nil || fi.ModTime().Equal(old) {
+		t.Errorf("output of changed source should be rewritten")
+	}
+	content, err := os.ReadFile(second)
+	if err != nil || !strings.Contains(string(content), "BIGINT") {
+		t.Errorf("output of changed source not regenerated: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "20000301000000_third.sql")); err != nil {
+		t.Errorf("missing output not regenerated: %v", err)
+	}
+}
+
+func TestDiffConversions(t *testing.T) {
+	testFS := fstest.MapFS{
+		"V1__first.sql":  {Data: []byte("CREATE TABLE a (id INT);\n")},
+		"V2__second.sql": {Data: []byte("CREATE FUNCTION f() RETURNS INT AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\n")},
+	}
+
+	dirA, dirB := t.TempDir(), t.TempDir()
+	cfg := &Config{BaseYear: "2000", EmitChecksums: true}
+	if err := processFS(testFS, dirA, cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if err := processFS(testFS, dirB, cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	diffs, err := DiffConversions(dirA, dirB)
+	if err != nil {
+		t.Fatalf("DiffConversions() error = %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("DiffConversions() = %v, want no differences", diffs)
+	}
+
+	// 忽略的行不参与比较
+	first := filepath.Join(dirB, "20000101000000_first.sql")
+	content, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(first, append([]byte("-- converted at 2024-01-01T00:00:00Z\n"), content...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignore := regexp.MustCompile(`^-- converted at `)
+	if diffs, err := DiffConversions(dirA, dirB, ignore); err != nil || len(diffs) != 0 {
+		t.Fatalf("DiffConversions() = %v, %v, want no differences", diffs, err)
+	}
+
+	// 人为制造的差异需要被发现
+	second := filepath.Join(dirB, "20000201000000_second.sql")
+	if err := os.WriteFile(second, []byte("-- +goose Up\nSELECT 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "extra.sql"), []byte("SELECT 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = DiffConversions(dirA, dirB, ignore)
+	if err != nil {
+		t.Fatalf("DiffConversions() error = %v", err)
+	}
+	expected := []string{
+		"20000201000000_second.sql: differs at line 2",
+		"extra.sql: only in " + dirB,
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("DiffConversions() = %v, want %v", diffs, expected)
+	}
+}
+
+// TestEmitFlywayChecksum 测试在生成文件中记录 Flyway 的 checksum
+func TestEmitFlywayChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int32
+	}{
+		{"空文件", "", 0},
+		{"换行符不计入", "SELECT 1;\n", int32(crc32.ChecksumIEEE([]byte("SELECT 1;")))},
+		{"不同的换行符结果相同", "a\r\nb\rc\n", int32(crc32.ChecksumIEEE([]byte("abc")))},
+		{"去掉 BOM", "\uFEFFa\nb", int32(crc32.ChecksumIEEE([]byte("ab")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flywayChecksum(tt.content); got != tt.want {
+				t.Errorf("flywayChecksum() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	source := "CREATE TABLE users (id INT);\n"
+	testFS := fstest.MapFS{
+		"V1__init.sql": {Data: []byte(source)},
+	}
+	for _, emit := range []bool{false, true} {
+		outputDir := t.TempDir()
+		if err := processFS(testFS, outputDir, &Config{BaseYear: "2000", EmitFlywayChecksum: emit}); err != nil {
+			t.Fatalf("processFS() error = %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(outputDir, "20000101000000_init.sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		comment := fmt.Sprintf("-- flyway-checksum: %d\n-- +goose Up\n", flywayChecksum(source))
+		if got := strings.HasPrefix(string(content), comment); got != emit {
+			t.Errorf("EmitFlywayChecksum = %v, content =\n%s", emit, content)
+		}
+	}
+}
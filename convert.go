@@ -1,33 +1,193 @@
 package goflyway
 
 import (
+	"fmt"
 	"io"
+	"path"
 	"regexp"
 	"strings"
 )
 
 var SqlHandleHooks []func(string) (string, error)
 
-// 预编译的正则表达式，用于匹配 Goose StatementBegin/StatementEnd 指令（goose 部分可选）
-var gooseStatementDirectiveRE = regexp.MustCompile(`(?i)--\s*\+(goose\s+)?Statement(Begin|End)`)
+// StatementContext 是 SqlHandleHooksEx 中的钩子得到的语句信息
+type StatementContext struct {
+	Source               string // Flyway 文件的路径，转换的不是文件(如 ConvertString)时为空
+	Index                int    // 语句在 Up 或 Down 部分中的序号，从 1 开始
+	Down                 bool   // 是否为 Down 部分的语句
+	HasInternalSemicolon bool   // 语句中间是否有分号，为 true 时语句会被 StatementBegin/StatementEnd 包围
+	Delimiter            string // 语句在源文件中使用的分隔符，默认为 ";"，在 DELIMITER 命令之后为该命令指定的分隔符
+}
+
+// SqlHandleHooksEx 与 SqlHandleHooks 相同，但钩子还能得到语句所在的文件和位置等信息，
+// 在 SqlHandleHooks 之后执行
+var SqlHandleHooksEx []func(ctx StatementContext, stmt string) (string, error)
+
+// sqlHandleHooks 返回 cfg 中的钩子，cfg 中没有设置任何钩子时返回全局的 SqlHandleHooks 和 SqlHandleHooksEx
+func (cfg *Config) sqlHandleHooks() ([]func(string) (string, error), []func(StatementContext, string) (string, error)) {
+	if cfg.SqlHandleHooks == nil && cfg.SqlHandleHooksEx == nil {
+		return SqlHandleHooks, SqlHandleHooksEx
+	}
+	return cfg.SqlHandleHooks, cfg.SqlHandleHooksEx
+}
 
 // 预编译的正则表达式，用于匹配不带 goose 的 statementBegin/statementEnd 指令
 var legacyStatementDirectiveRE = regexp.MustCompile(`(?i)--\s*\+statement(Begin|End)`)
 
+// DefaultDownStub 是没有 Down 语句的迁移默认的 Down 部分
+const DefaultDownStub = "-- Down migration is not supported in automatic conversion"
+
+// downStub 返回没有 Down 语句时 Down 部分的内容，以换行结尾
+func (cfg *Config) downStub(source string) string {
+	stub := cfg.DownStub
+	if stub == "" {
+		stub = DefaultDownStub
+	}
+	stub = strings.ReplaceAll(stub, "{source}", source)
+	if !strings.HasSuffix(stub, "\n") {
+		stub += "\n"
+	}
+	return stub
+}
+
 // ConvertFlywayToGoose 将 Flyway SQL 转换为 Goose SQL 格式
 func ConvertFlywayToGoose(in io.Reader) (string, error) {
-	// 分割 SQL 语句
-	statements, err := Split(in)
+	return convertFlywayToGoose(in, &Config{})
+}
+
+// ConvertFlywayToGooseWithConfig 与 ConvertFlywayToGoose 相同，按 cfg 中的选项(如 DownStub)转换
+func ConvertFlywayToGooseWithConfig(in io.Reader, cfg *Config) (string, error) {
+	return convertFlywayToGoose(in, cfg)
+}
+
+// ConvertString 与 ConvertFlywayToGoose 相同，输入和输出都是字符串
+func ConvertString(flywaySQL string) (string, error) {
+	return ConvertFlywayToGoose(strings.NewReader(flywaySQL))
+}
+
+// convertFlywayToGoose 按 cfg 中的选项将 Flyway SQL 转换为 Goose SQL 格式
+func convertFlywayToGoose(in io.Reader, cfg *Config) (string, error) {
+	content, _, err := convertFlywayToGooseWithUndo(in, nil, "", cfg)
+	return content, err
+}
+
+// convertFlywayToGooseWithUndo 与 convertFlywayToGoose 相同，undo 不为 nil 时
+// 将其中的 Flyway undo 脚本转换后作为 -- +goose Down 部分，返回的 bool 表示是否生成了 Down 部分。
+// source 为 Flyway 文件的路径，传给 SqlHandleHooksEx
+func convertFlywayToGooseWithUndo(in, undo io.Reader, source string, cfg *Config) (string, bool, error) {
+	format, err := gooseFormatFor(cfg.GooseVersion)
 	if err != nil {
-		return "", err
+		return "", false, err
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", false, err
+	}
+
+	// 标记了 NO TRANSACTION 的脚本不能放在事务中执行
+	wrapTransaction := cfg.WrapTransaction && !gooseNoTransactionRE.Match(data)
+	begin, commit := transactionStatements(cfg.DBDriver)
+
+	var useSchema, restoreSchema string
+	if cfg.TargetSchema != "" {
+		useSchema, restoreSchema, err = targetSchemaStatements(cfg.DBDriver, cfg.TargetSchema, cfg.RestoreSchema)
+		if err != nil {
+			return "", false, err
+		}
 	}
 
 	var result strings.Builder
 	result.WriteString("-- +goose Up\n")
+	if useSchema != "" {
+		result.WriteString(useSchema + "\n")
+	}
+	if wrapTransaction {
+		result.WriteString(begin + "\n")
+	}
+	if err := writeGooseStatements(&result, strings.NewReader(string(data)), StatementContext{Source: source}, cfg, format); err != nil {
+		return "", false, err
+	}
+	if wrapTransaction {
+		result.WriteString(commit + "\n")
+	}
+	if restoreSchema != "" {
+		result.WriteString(restoreSchema + "\n")
+	}
 
-	for _, stmt := range statements {
+	// 没有 undo 脚本时，所有语句都能反转才生成 Down 部分
+	if undo == nil && cfg.GenerateDown {
+		down, complete, err := GenerateDown(string(data))
+		if err != nil {
+			return "", false, err
+		}
+		if complete {
+			undo = strings.NewReader(down)
+		}
+	}
+
+	if format.blankBeforeDown {
+		result.WriteString("\n")
+	}
+	result.WriteString("-- +goose Down\n")
+	if undo == nil {
+		result.WriteString(cfg.downStub(source))
+		return result.String(), false, nil
+	}
+	if err := writeGooseStatements(&result, undo, StatementContext{Source: source, Down: true}, cfg, format); err != nil {
+		return "", false, err
+	}
+	return result.String(), true, nil
+}
+
+// writeGooseStatements 将 in 中的 SQL 分割成语句，需要时加上 StatementBegin/StatementEnd 后写入 result，
+// base 中的 Source 和 Down 用于 SqlHandleHooksEx
+func writeGooseStatements(result *strings.Builder, in io.Reader, base StatementContext, cfg *Config, format gooseFormat) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	text := handlePsqlPreamble(string(data), cfg.StripPsqlMeta, cfg.logger())
+	text = rewriteFlywayDirectives(text, cfg.FlywayDirectives, cfg.tokenizerOptions())
+
+	// 分割 SQL 语句，已经用 StatementBegin/StatementEnd 包围的语句不再重复包围
+	detailed, err := splitDetailed(strings.NewReader(text), cfg.tokenizerOptions(), cfg.logger())
+	if err != nil {
+		return err
+	}
+	statements := make([]string, len(detailed))
+	annotated := map[string]bool{}
+	delimiters := map[string]string{}
+	for idx, stmt := range detailed {
+		statements[idx] = stmt.Text
+		if stmt.Annotated {
+			annotated[stmt.Text] = true
+		}
+		delimiters[stmt.Text] = stmt.Delimiter
+	}
+	if err := checkStatementSize(statements, cfg.maxStatementBytes()); err != nil {
+		return err
+	}
+
+	if len(cfg.StripStatements) > 0 || cfg.StripStatementFunc != nil {
+		statements, err = stripEdgeStatements(statements, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.DropEmptyStatements {
+		statements = dropEmptyStatements(statements)
+	}
+
+	hooks, hooksEx := cfg.sqlHandleHooks()
+	number := 0
+	lastBlock := false // 最后一条语句是否在 StatementBegin/StatementEnd 块中
+
+	for idx, stmt := range statements {
 		// 保留语句中的原始换行和缩进
 		trimmedStmt := stmt
+		var leadingLines strings.Builder
 
 		for {
 			idx := strings.IndexRune(trimmedStmt, '\n')
@@ -40,33 +200,87 @@ func ConvertFlywayToGoose(in io.Reader) (string, error) {
 			}
 
 			trimmedStmt = trimmedStmt[idx+1:]
-			result.WriteString(line)
+			leadingLines.WriteString(line)
 		}
 
 		// 转换旧的 statementBegin/statementEnd 指令为 goose 格式
 		trimmedStmt = legacyStatementDirectiveRE.ReplaceAllString(trimmedStmt, "-- +goose statement$1")
 
+		if cfg.CanonicalizeWhitespace {
+			trimmedStmt, err = canonicalizeWhitespace(trimmedStmt)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cfg.KeywordCase != KeywordCasePreserve {
+			trimmedStmt, err = applyKeywordCase(trimmedStmt, cfg.KeywordCase)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(cfg.SchemaRewrites) > 0 {
+			trimmedStmt, err = rewriteSchemas(trimmedStmt, cfg.SchemaRewrites)
+			if err != nil {
+				return err
+			}
+		}
+
 		// 检查语句是否包含内部分号（除结尾分号外）
 		hasInternalSemicolon := hasInternalSemicolon(trimmedStmt)
 
-		for _, hook := range SqlHandleHooks {
-			trimmedStmt, err = hook(trimmedStmt)
+		if hasInternalSemicolon && len(cfg.UnwrappedStatementKinds) > 0 {
+			unwrapped, err := isUnwrappedKind(trimmedStmt, cfg.UnwrappedStatementKinds)
 			if err != nil {
-				return "", err
+				return err
+			}
+			if unwrapped {
+				hasInternalSemicolon = false
 			}
 		}
 
-		////////////////////////////////////////////
-		// 我自已有一部份老代码中有这个
-		// 使用正则表达式匹配，忽略大小写和空白字符变化
-		if gooseStatementDirectiveRE.MatchString(trimmedStmt) {
+		if annotated[stmt] {
 			hasInternalSemicolon = false
 		}
-		////////////////////////////////////////////
+
+		for _, hook := range hooks {
+			trimmedStmt, err = hook(trimmedStmt)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(hooksEx) > 0 {
+			ctx := base
+			ctx.Index = idx + 1
+			ctx.HasInternalSemicolon = hasInternalSemicolon
+			ctx.Delimiter = delimiters[stmt]
+			if ctx.Delimiter == "" {
+				ctx.Delimiter = ";"
+			}
+			for _, hook := range hooksEx {
+				trimmedStmt, err = hook(ctx, trimmedStmt)
+				if err != nil {
+					return err
+				}
+			}
+		}
 
 		// 对于复杂语句，添加额外的 Goose 指令
+		if !hasInternalSemicolon || format.blankBeforeBlock {
+			result.WriteString(leadingLines.String())
+		}
+		if hasInternalSemicolon && format.blankBeforeBlock {
+			result.WriteString("\n")
+		}
+		if cfg.AnnotateStatements && !isEmptyOrComments(trimmedStmt) {
+			// 注释放在 StatementBegin 之前，不影响 goose 对语句的分割
+			number++
+			result.WriteString(statementAnnotation(base, number))
+		}
 		if hasInternalSemicolon {
-			result.WriteString("\n-- +goose StatementBegin\n")
+			result.WriteString("-- +goose StatementBegin\n")
 		}
 
 		// 添加语句内容
@@ -76,21 +290,159 @@ func ConvertFlywayToGoose(in io.Reader) (string, error) {
 		if hasInternalSemicolon {
 			result.WriteString("\n-- +goose StatementEnd")
 		}
+		lastBlock = hasInternalSemicolon || annotated[stmt]
 
 		result.WriteString("\n")
 	}
 
 	if len(statements) > 0 {
-		// 如果最后一个语句已经包含 Goose 指令，则不需要添加分号
-		if !hasSemicolonAtEnt(statements[len(statements)-1]) {
+		// 如果最后一个语句已经包含 Goose 指令或在 StatementBegin/StatementEnd 块中，则不需要添加分号
+		if !lastBlock && !hasSemicolonAtEnt(statements[len(statements)-1]) {
 			result.WriteString(";\n")
 		}
 	}
 
-	result.WriteString("\n-- +goose Down")
-	result.WriteString("\n-- Down migration is not supported in automatic conversion")
-	result.WriteString("\n")
-	return result.String(), nil
+	return nil
+}
+
+// statementAnnotation 返回 Config.AnnotateStatements 时加在第 number 条语句前的注释
+func statementAnnotation(base StatementContext, number int) string {
+	prefix := "-- stmt"
+	if base.Down {
+		prefix = "-- down stmt"
+	}
+	if base.Source == "" {
+		return fmt.Sprintf("%s %d\n", prefix, number)
+	}
+	return fmt.Sprintf("%s %d of %s\n", prefix, number, path.Base(base.Source))
+}
+
+// gooseNoTransactionRE 匹配 goose 的 NO TRANSACTION 注解
+var gooseNoTransactionRE = regexp.MustCompile(`(?i)--\s*\+goose\s+NO\s+TRANSACTION`)
+
+// transactionStatements 返回 driver 对应数据库开始和提交事务的语句
+func transactionStatements(driver string) (begin, commit string) {
+	switch DriverFamily(driver) {
+	case "mysql":
+		return "START TRANSACTION;", "COMMIT;"
+	case "sqlserver":
+		return "BEGIN TRANSACTION;", "COMMIT TRANSACTION;"
+	case "oracle":
+		// Oracle 在第一条 DML 时隐式开始事务
+		return "SET TRANSACTION READ WRITE;", "COMMIT;"
+	default:
+		return "BEGIN;", "COMMIT;"
+	}
+}
+
+// targetSchemaIdentRE 匹配可以不加引号直接写入 SQL 的 schema 名
+var targetSchemaIdentRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// targetSchemaStatements 返回 driver 对应数据库切换到 schema 的语句，以及 Up 部分结束时切换回 restore 的语句。
+// goose 在执行迁移的同一个事务中写入不带 schema 的版本表，必须先切换回去，否则版本记录会写到 schema 中。
+// restore 为空时 Postgres 恢复切换前的 search_path，Oracle 恢复为登录用户的 schema，MySQL 和 SQL Server 无法恢复而报错
+func targetSchemaStatements(driver, schema, restore string) (use, reset string, err error) {
+	for _, name := range []string{schema, restore} {
+		if name != "" && !targetSchemaIdentRE.MatchString(name) {
+			return "", "", fmt.Errorf("invalid target schema %q", name)
+		}
+	}
+	if schema == "" {
+		return "", "", fmt.Errorf("invalid target schema %q", schema)
+	}
+
+	switch DriverFamily(driver) {
+	case "mysql", "sqlserver":
+		if restore == "" {
+			return "", "", fmt.Errorf("target schema for driver %q requires a restore schema for the goose version table", driver)
+		}
+		return "USE " + schema + ";", "USE " + restore + ";", nil
+	case "oracle":
+		use = "ALTER SESSION SET CURRENT_SCHEMA = " + schema + ";"
+		if restore != "" {
+			return use, "ALTER SESSION SET CURRENT_SCHEMA = " + restore + ";", nil
+		}
+		return use, "-- +goose StatementBegin\nBEGIN\n  EXECUTE IMMEDIATE 'ALTER SESSION SET CURRENT_SCHEMA = ' || SYS_CONTEXT('USERENV', 'SESSION_USER');\nEND;\n-- +goose StatementEnd", nil
+	case "sqlite3":
+		return "", "", fmt.Errorf("target schema is not supported for driver %q", driver)
+	default:
+		if restore != "" {
+			return "SET search_path TO " + schema + ";", "SET search_path TO " + restore + ";", nil
+		}
+		return "SELECT set_config('goflyway.search_path', current_setting('search_path'), false);\nSET search_path TO " + schema + ";",
+			"SELECT set_config('search_path', current_setting('goflyway.search_path'), false);", nil
+	}
+}
+
+// dropEmptyStatements 去掉空语句、只有分号的语句和只有注释的语句，包含 goose 注解的语句保留
+func dropEmptyStatements(statements []string) []string {
+	result := statements[:0:0]
+	for _, stmt := range statements {
+		body := strings.TrimRight(strings.TrimSpace(stmt), ";")
+		if isEmptyOrComments(body) && !strings.Contains(body, "+goose") {
+			continue
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// checkStatementSize 检查是否有语句超过 limit 字节。未闭合的引号或 dollar-quote 会让
+// 文件剩余的全部内容被当作一条语句，这里报告该语句的起始行和开头内容以便定位
+func checkStatementSize(statements []string, limit int) error {
+	line := 1
+	for _, stmt := range statements {
+		if len(stmt) > limit {
+			trimmed := strings.TrimLeft(stmt, " \t\r\n")
+			start := line + strings.Count(stmt[:len(stmt)-len(trimmed)], "\n")
+			head, _, _ := strings.Cut(trimmed, "\n")
+			if len(head) > 60 {
+				head = head[:60]
+			}
+			return fmt.Errorf("statement starting at line %d exceeds %d bytes, possibly an unterminated quote, dollar-quote or comment: %q", start, limit, head)
+		}
+		line += strings.Count(stmt, "\n")
+	}
+	return nil
+}
+
+// gooseFormat 描述不同 goose 版本对注解前后空行的要求
+type gooseFormat struct {
+	blankBeforeBlock bool // StatementBegin 之前是否允许空行
+	blankBeforeDown  bool // +goose Down 之前是否加空行
+}
+
+// gooseFormats 按 goose 主版本号索引，旧版本的解析器不接受注解前的空行
+var gooseFormats = map[string]gooseFormat{
+	"2": {blankBeforeBlock: false, blankBeforeDown: false},
+	"3": {blankBeforeBlock: true, blankBeforeDown: true},
+}
+
+// gooseFormatFor 根据 "v2"、"3"、"v3.26.0" 这样的版本号返回输出格式，为空时使用 v3 格式
+func gooseFormatFor(version string) (gooseFormat, error) {
+	if version == "" {
+		return gooseFormats["3"], nil
+	}
+	major, _, _ := strings.Cut(strings.TrimPrefix(strings.ToLower(version), "v"), ".")
+	format, ok := gooseFormats[major]
+	if !ok {
+		return gooseFormat{}, fmt.Errorf("unsupported goose version %q", version)
+	}
+	return format, nil
+}
+
+// isUnwrappedKind 检查语句类型是否在 kinds 中
+func isUnwrappedKind(stmt string, kinds []string) (bool, error) {
+	kind, err := ClassifyStatement(stmt)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range kinds {
+		if strings.EqualFold(strings.Join(strings.Fields(k), " "), kind) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // hasInternalSemicolon 检查语句是否包含内部分号（非结尾分号）
@@ -129,6 +481,102 @@ func hasInternalSemicolon(stmt string) bool {
 	return strings.Contains(trimmed, ";")
 }
 
+// warnMissingTerminators 在 cfg.WarnMissingSemicolon 时对没有以分号结尾的语句输出警告：
+// 最后一条语句转换时会自动补上分号，中间的语句会被 goose 与下一条语句合并执行。
+// 两条语句之间缺少分号时 Split 会把它们当作一条语句，因此还会检查语句中间从行首开始的 CREATE、INSERT 等关键字
+func warnMissingTerminators(path, text string, cfg *Config) error {
+	if !cfg.WarnMissingSemicolon {
+		return nil
+	}
+	statements, err := splitDetailed(strings.NewReader(text), cfg.tokenizerOptions(), cfg.logger())
+	if err != nil {
+		return fmt.Errorf("failed to split %s: %w", path, err)
+	}
+
+	line := 1
+	for idx, stmt := range statements {
+		trimmed := strings.TrimLeft(stmt.Text, " \t\r\n")
+		start := line + strings.Count(stmt.Text[:len(stmt.Text)-len(trimmed)], "\n")
+		if !stmt.Annotated {
+			for _, offset := range mergedStatementLines(stmt.Text, cfg.tokenizerOptions()) {
+				cfg.logger().Warnf("%s: line %d starts a new statement but the statement before it has no terminating semicolon", path, line+offset)
+			}
+		}
+		line += strings.Count(stmt.Text, "\n")
+
+		switch {
+		case idx == len(statements)-1:
+			if !hasSemicolonAtEnt(stmt.Text) {
+				cfg.logger().Warnf("%s: the last statement (line %d) has no terminating semicolon, one was added", path, start)
+			}
+		case stmt.Annotated || isEmptyOrComments(stmt.Text):
+		case !hasSemicolonAtEnt(stmt.Text):
+			cfg.logger().Warnf("%s: statement at line %d has no terminating semicolon", path, start)
+		}
+	}
+	return nil
+}
+
+// statementStartKeywords 通常只出现在语句开头的关键字
+var statementStartKeywords = map[string]bool{
+	"CREATE":   true,
+	"ALTER":    true,
+	"DROP":     true,
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"GRANT":    true,
+	"REVOKE":   true,
+	"TRUNCATE": true,
+}
+
+// mergedStatementLines 返回 stmt 中间从行首(没有缩进)开始、在括号和 BEGIN/END 块之外的 statementStartKeywords 所在的行，
+// 行号从 stmt 的第一行为 0 开始。这通常是前一条语句缺少分号，两条语句被合并成了一条
+func mergedStatementLines(stmt string, opts TokenizerOptions) []int {
+	opts.CommentTokens = true
+	tokenizer := NewTokenizerWithOptions(strings.NewReader(stmt), opts)
+
+	var lines []int
+	line, depth := 0, 0
+	atLineStart, seenContent := true, false
+	for {
+		token, err := tokenizer.NextToken()
+		if err != nil && err != io.EOF {
+			return nil
+		}
+
+		value := token.Value
+		switch token.Type {
+		case TokenBegin:
+			depth++
+		case TokenEnd:
+			depth--
+		case TokenText:
+			switch value {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			if fields := strings.Fields(value); atLineStart && seenContent && depth == 0 && len(fields) > 0 &&
+				statementStartKeywords[strings.ToUpper(fields[0])] {
+				lines = append(lines, line)
+			}
+		}
+		if token.Type != TokenComment && strings.TrimSpace(value) != "" {
+			seenContent = true
+		}
+		if value != "" {
+			line += strings.Count(value, "\n")
+			atLineStart = strings.HasSuffix(value, "\n")
+		}
+
+		if err == io.EOF {
+			return lines
+		}
+	}
+}
+
 func hasSemicolonAtEnt(stmt string) bool {
 	lines := strings.Split(stmt, "\n")
 
@@ -151,3 +599,205 @@ func hasSemicolonAtEnt(stmt string) bool {
 
 	return strings.HasSuffix(lastNonCommentLine, ";")
 }
+
+// stripEdgeStatements 去掉文件开头和结尾与 cfg.StripStatements 或 cfg.StripStatementFunc 匹配的语句，
+// 只有注释的语句不参与匹配并保留
+func stripEdgeStatements(statements []string, cfg *Config) ([]string, error) {
+	var patterns []string
+	for _, s := range cfg.StripStatements {
+		normalized, err := normalizeStatementText(s)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, normalized)
+	}
+
+	match := func(stmt string) (bool, error) {
+		if cfg.StripStatementFunc != nil && cfg.StripStatementFunc(stmt) {
+			return true, nil
+		}
+		normalized, err := normalizeStatementText(stmt)
+		if err != nil {
+			return false, err
+		}
+		for _, pattern := range patterns {
+			if normalized == pattern {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// 去掉开头的语句
+	for i := 0; i < len(statements); {
+		if isEmptyOrComments(statements[i]) {
+			i++
+			continue
+		}
+		ok, err := match(statements[i])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		count := len(statements)
+		statements = removeStatement(statements, i)
+		if len(statements) == count {
+			// 保留了语句前的注释
+			i++
+		}
+	}
+
+	// 去掉结尾的语句
+	for i := len(statements) - 1; i >= 0; i-- {
+		if isEmptyOrComments(statements[i]) {
+			continue
+		}
+		ok, err := match(statements[i])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		statements = removeStatement(statements, i)
+	}
+	return statements, nil
+}
+
+// removeStatement 去掉第 i 条语句，语句前面的注释会作为单独的一条保留下来
+func removeStatement(statements []string, i int) []string {
+	var comments []string
+	for _, line := range strings.SplitAfter(statements[i], "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			break
+		}
+		comments = append(comments, line)
+	}
+
+	result := append([]string{}, statements[:i]...)
+	if prefix := strings.TrimRight(strings.Join(comments, ""), "\n"); strings.TrimSpace(prefix) != "" {
+		result = append(result, prefix)
+	}
+	return append(result, statements[i+1:]...)
+}
+
+// normalizeStatementText 返回去掉注释、多余空白和结尾分号并转为大写后的语句文本
+func normalizeStatementText(stmt string) (string, error) {
+	tokens, err := significantTokens(stmt)
+	if err != nil {
+		return "", err
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return strings.ToUpper(strings.Join(tokens, " ")), nil
+}
+
+// flywayDirectiveRE 匹配 "-- flyway:timeout=30" 这样的 Flyway 指令注释
+var flywayDirectiveRE = regexp.MustCompile(`^--\s*flyway:([A-Za-z][\w.-]*)\s*(?:=\s*(.*?))?\s*$`)
+
+// rewriteFlywayDirectives 按 directives 去掉或替换单独占一行的 Flyway 指令注释，directives 为 nil 时使用 DefaultFlywayDirectives。
+// 只处理 Tokenizer 识别出的注释，字符串和 dollar-quote 中的内容保持不变，未列出的指令原样保留
+func rewriteFlywayDirectives(text string, directives map[string]string, opts TokenizerOptions) string {
+	if !strings.Contains(text, "flyway:") {
+		return text
+	}
+	if directives == nil {
+		directives = DefaultFlywayDirectives
+	}
+
+	opts.CommentTokens = true
+	tokenizer := NewTokenizerWithOptions(strings.NewReader(text), opts)
+	var result strings.Builder
+	atLineStart := true
+	indent := "" // 行首还没有写入的空白，整行被去掉时一起去掉
+	for {
+		token, err := tokenizer.NextToken()
+		if err != nil && err != io.EOF {
+			// 未闭合的引号等错误留给之后分割语句时报告
+			return text
+		}
+
+		value := token.Value
+		switch {
+		case atLineStart && strings.TrimSpace(value) == "":
+			if strings.Contains(value, "\n") {
+				result.WriteString(indent + value)
+				indent = ""
+			} else {
+				indent += value
+			}
+		case atLineStart && token.Type == TokenComment && isFlywayDirective(value, directives):
+			m := flywayDirectiveRE.FindStringSubmatch(value)
+			replacement := directives[strings.ToLower(m[1])]
+			if replacement != "" {
+				if strings.Contains(replacement, "%s") {
+					replacement = fmt.Sprintf(replacement, m[2])
+				}
+				result.WriteString(replacement)
+				if strings.HasSuffix(value, "\n") {
+					result.WriteString("\n")
+				}
+			}
+			indent = ""
+		default:
+			result.WriteString(indent + value)
+			indent = ""
+			atLineStart = strings.HasSuffix(value, "\n")
+		}
+
+		if err == io.EOF {
+			result.WriteString(indent)
+			return result.String()
+		}
+	}
+}
+
+// isFlywayDirective 判断注释是否为 directives 中列出的 Flyway 指令
+func isFlywayDirective(comment string, directives map[string]string) bool {
+	m := flywayDirectiveRE.FindStringSubmatch(comment)
+	if m == nil {
+		return false
+	}
+	_, ok := directives[strings.ToLower(m[1])]
+	return ok
+}
+
+// handlePsqlPreamble 处理脚本开头的 psql 元命令(如 \set、\connect、\i)和 #! 行，
+// goose 无法执行它们：strip 为 true 时去掉，否则原样保留并输出警告
+func handlePsqlPreamble(text string, strip bool, logger Logger) string {
+	var result strings.Builder
+	rest := text
+	for rest != "" {
+		line := rest
+		if idx := strings.IndexByte(rest, '\n'); idx >= 0 {
+			line = rest[:idx+1]
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			result.WriteString(line)
+			rest = rest[len(line):]
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "\\") && !strings.HasPrefix(trimmed, "#!") {
+			break
+		}
+
+		if strip {
+			logger.Warnf("stripped non-SQL preamble line %q", trimmed)
+		} else {
+			logger.Warnf("non-SQL preamble line %q is not supported by goose", trimmed)
+			result.WriteString(line)
+		}
+		rest = rest[len(line):]
+	}
+
+	if result.Len() == len(text)-len(rest) {
+		// 没有需要去掉的内容
+		return text
+	}
+	return result.String() + rest
+}
@@ -1,12 +1,16 @@
 package goflyway
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -29,58 +33,350 @@ func CopyMigrateTable(
 	gooseTable string, // Goose表名
 	baseYear string, // 年份
 ) error {
+	return CopyMigrateTableContext(context.Background(), driver, db, flywayTable, gooseTable, baseYear)
+}
+
+// CopyMigrateTableContext 与 CopyMigrateTable 相同，所有数据库操作都使用 ctx，可以取消或设置超时
+func CopyMigrateTableContext(
+	ctx context.Context,
+	driver string,
+	db *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+) error {
+	_, err := copyMigrateTable(ctx, driver, db, flywayTable, gooseTable, baseYear, CopyOptions{})
+	return err
+}
+
+// CopyResult 记录一次版本表复制的结果
+type CopyResult struct {
+	Inserted  int      `json:"inserted"`  // 写入 Goose 表的版本数(不含 version_id=0 的记录)
+	Skipped   int      `json:"skipped"`   // Flyway 表中重复出现而跳过的记录数
+	Conflicts []string `json:"conflicts"` // 与其它 Flyway 版本转换成同一个 Goose 版本而跳过的版本
+	Versions  []int64  `json:"versions"`  // 写入 Goose 表的版本
+	Existing  []int64  `json:"existing"`  // Goose 表中已存在的版本(按 ConflictMode 跳过或覆盖)
+
+	// OutOfOrder Flyway outOfOrder 模式下在更高的版本之后才执行的版本
+	OutOfOrder []string `json:"out_of_order,omitempty"`
+
+	// LastInstalledRank 和 LastInstalledOn 为 Flyway 表中最后执行的记录，指定了 CopyOptions.Watermark 时才有值，
+	// 可以作为下一次增量复制的水位
+	LastInstalledRank int64     `json:"last_installed_rank,omitempty"`
+	LastInstalledOn   time.Time `json:"last_installed_on,omitempty"`
+}
+
+// ConflictMode 表示 Goose 表中已存在相同版本时的处理方式
+type ConflictMode string
+
+const (
+	// ConflictSkip 跳过已存在的版本
+	ConflictSkip ConflictMode = "skip"
+	// ConflictError 遇到已存在的版本时报错，不写入任何记录
+	ConflictError ConflictMode = "error"
+	// ConflictOverwrite 用 Flyway 的记录覆盖已存在的版本
+	ConflictOverwrite ConflictMode = "overwrite"
+)
+
+// CopyOptions 复制版本表的选项
+type CopyOptions struct {
+	// ConflictMode Goose 表已存在时对其中相同版本的处理方式，默认为 ConflictSkip，因此可以安全地重复执行
+	ConflictMode ConflictMode
+
+	// PreserveChecksum 为 true 时，Goose 表增加 checksum 列并保存 Flyway 记录的 checksum，
+	// 以便之后校验磁盘上的脚本是否被修改过
+	PreserveChecksum bool
+
+	// StrictOrder 为 true 时，Flyway 表中的执行顺序与版本顺序不一致(outOfOrder 模式下先执行了更高的版本)时报错，
+	// 否则只输出警告
+	StrictOrder bool
+
+	// VersionStrategy 为 Flyway 版本号转换为 goose 版本号的方式，应与转换迁移脚本时的 Config.VersionStrategy 相同，
+	// 为空时使用 VersionStrategyAuto
+	VersionStrategy string
+
+	// VersionScheme 为 Flyway 版本号转换为 goose 版本号的方案，应与转换迁移脚本时的 Config.VersionScheme 相同，
+	// 为 nil 时使用 DefaultVersionScheme
+	VersionScheme *VersionScheme
+
+	// Numbering 不为 nil 时按它把 Flyway 版本号映射为 goose 版本，而不是用 VersionScheme 编码，
+	// 用于转换时重新编号(GooseNumberingSequential/GooseNumberingStride)的迁移，值为 ConversionReport.FlywayVersions
+	Numbering map[string]int64
+
+	// BatchSize 大于 1 时用多行 INSERT 每次写入最多 BatchSize 条记录，Oracle 不支持多行 VALUES，仍然逐条写入
+	BatchSize int
+
+	// VersionIDColumnBits 为 Goose 表 version_id 列的整数位数，应与转换时的 Config.VersionIDColumnBits 相同，
+	// 为 32 时任何一个版本超出 INTEGER 的范围都报错，不写入任何记录
+	VersionIDColumnBits int
+
+	// Watermark 不为 nil 时只复制水位之后的记录，新旧工具并行运行期间可以反复执行，逐步把新的记录同步到 Goose 表
+	Watermark *CopyWatermark
+}
+
+// gooseVersion 将 Flyway 版本号转换为 goose 版本
+func (opts CopyOptions) gooseVersion(version, baseYear string) (int64, error) {
+	if opts.Numbering != nil {
+		if versionID, ok := opts.Numbering[version]; ok {
+			return versionID, nil
+		}
+		for flywayVersion, versionID := range opts.Numbering {
+			if compareFlywayVersions(flywayVersion, version) == 0 {
+				return versionID, nil
+			}
+		}
+		return 0, fmt.Errorf("版本转换失败: 版本 %s 没有对应的迁移脚本", version)
+	}
+
+	timestampVersion, err := convertVersion(version, baseYear, opts.VersionStrategy, opts.VersionScheme)
+	if err != nil {
+		return 0, fmt.Errorf("版本转换失败: %s", err)
+	}
+	versionID, err := strconv.ParseInt(timestampVersion, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("版本转换失败: %s", err)
+	}
+	return versionID, nil
+}
+
+// CopyWatermark 增量复制的水位，设置了多个条件时记录需要同时满足
+type CopyWatermark struct {
+	InstalledRank int64     // 只复制 installed_rank 大于它的记录，为 0 时不限制
+	InstalledOn   time.Time // 只复制 installed_on 晚于它的记录，为零值时不限制
+
+	// FromGoose 为 true 时以 Goose 表中已有的最大版本为水位，只复制转换后版本大于它的记录。
+	// 在更高的版本之后才执行的版本(Flyway outOfOrder)不会被复制，这时应使用 InstalledRank
+	FromGoose bool
+}
+
+// includes 判断 migration 是否在水位之后
+func (w *CopyWatermark) includes(migration flywayMigrateResult) bool {
+	if w.InstalledRank > 0 && migration.installedRank <= w.InstalledRank {
+		return false
+	}
+	if !w.InstalledOn.IsZero() && !migration.installedOn.After(w.InstalledOn) {
+		return false
+	}
+	return true
+}
+
+// CopyMigrateTableWithResult 与 CopyMigrateTable 相同，并返回复制结果
+func CopyMigrateTableWithResult(
+	driver string,
+	db *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+) (*CopyResult, error) {
+	return CopyMigrateTableWithOptions(driver, db, flywayTable, gooseTable, baseYear, CopyOptions{})
+}
+
+// CopyMigrateTableWithOptions 与 CopyMigrateTableWithResult 相同，可以通过 opts 指定冲突处理方式
+func CopyMigrateTableWithOptions(
+	driver string,
+	db *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+	opts CopyOptions,
+) (*CopyResult, error) {
+	return copyMigrateTable(context.Background(), driver, db, flywayTable, gooseTable, baseYear, opts)
+}
+
+// CopyMigrateTableAcross 与 CopyMigrateTable 相同，但从 srcDB 中读取 Flyway 表，写入 dstDB 中的 Goose 表，
+// 两个数据库可以是不同的类型(如 MySQL → Postgres)，各自按 srcDriver 和 dstDriver 生成 SQL
+func CopyMigrateTableAcross(
+	srcDriver string,
+	srcDB *sql.DB,
+	dstDriver string,
+	dstDB *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+) error {
+	_, err := copyMigrateTableAcross(context.Background(), srcDriver, srcDB, dstDriver, dstDB, flywayTable, gooseTable, baseYear, CopyOptions{})
+	return err
+}
+
+func copyMigrateTable(
+	ctx context.Context,
+	driver string,
+	db *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+	opts CopyOptions,
+) (*CopyResult, error) {
+	return copyMigrateTableAcross(ctx, driver, db, driver, db, flywayTable, gooseTable, baseYear, opts)
+}
+
+func copyMigrateTableAcross(
+	ctx context.Context,
+	srcDriver string,
+	srcDB *sql.DB,
+	dstDriver string,
+	dstDB *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+	opts CopyOptions,
+) (*CopyResult, error) {
+	switch opts.ConflictMode {
+	case "":
+		opts.ConflictMode = ConflictSkip
+	case ConflictSkip, ConflictError, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("不支持的冲突处理方式: %s", opts.ConflictMode)
+	}
+
 	// 1. 表名校验（防SQL注入）
-	if err := validateTableNames(flywayTable, gooseTable); err != nil {
-		return fmt.Errorf("表名非法: %s", err)
+	if err := validateTableNamesFor(srcDriver, flywayTable); err != nil {
+		return nil, fmt.Errorf("表名非法: %s", err)
+	}
+	if err := validateTableNamesFor(dstDriver, gooseTable); err != nil {
+		return nil, fmt.Errorf("表名非法: %s", err)
 	}
 
-	// 2. 获取最新Flyway版本记录
-	migrations, err := getAllFlywayVersions(db, driver, flywayTable)
+	// 2. 获取最新Flyway版本记录，增量复制时需要 installed_rank
+	migrations, err := getAllFlywayVersions(ctx, srcDB, srcDriver, flywayTable, opts.PreserveChecksum || opts.Watermark != nil)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
+			return nil, fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
 		}
-		return fmt.Errorf("读取Flyway版本失败: %s", err)
+		return nil, fmt.Errorf("读取Flyway版本失败: %w", err)
 	}
 
 	// 检查是否为空表
 	if len(migrations) == 0 {
-		return fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
+		return nil, fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
 	}
 
-	// 3. 创建Goose版本表（若不存在）
-	if err := createGooseTable(db, driver, gooseTable); err != nil {
-		return fmt.Errorf("创建Goose表失败: %s", err)
-	}
+	// goose 创建版本表时会先写入一条 version_id=0 的记录，这里保持一致，
+	// 使复制后的表与 goose 依次成功执行这些版本后的状态相同
+	rows := []gooseVersionRow{{version: 0, tstamp: migrations[0].installedOn}}
 
+	// goose 每个版本只有一条 is_applied=true 的记录
+	result := &CopyResult{}
+	applied := map[int64]string{}
+	var highest int64
 	for _, migration := range migrations {
 		if migration.version == "" {
-			return fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
+			return nil, fmt.Errorf("Flyway表 %s 无版本记录", flywayTable)
+		}
+		if opts.Watermark != nil {
+			if migration.installedRank > result.LastInstalledRank {
+				result.LastInstalledRank = migration.installedRank
+			}
+			if migration.installedOn.After(result.LastInstalledOn) {
+				result.LastInstalledOn = migration.installedOn
+			}
+			if !opts.Watermark.includes(migration) {
+				continue
+			}
 		}
 
-		// 4. 语义化版本 → 时间戳版本号
-		timestampVersion, err := convertToGooseTimestamp(migration.version, baseYear)
+		// 3. 语义化版本 → 时间戳版本号
+		versionID, err := opts.gooseVersion(migration.version, baseYear)
 		if err != nil {
-			return fmt.Errorf("版本转换失败: %s", err)
+			return nil, err
 		}
-		versionID, err := strconv.ParseInt(timestampVersion, 10, 64)
-		if err != nil {
-			return fmt.Errorf("版本转换失败: %s", err)
+		if err := checkVersionIDBits(versionID, opts.VersionIDColumnBits); err != nil {
+			return nil, fmt.Errorf("版本 %s: %w", migration.version, err)
+		}
+
+		if previous, ok := applied[versionID]; ok {
+			if previous == migration.version {
+				result.Skipped++
+			} else {
+				result.Conflicts = append(result.Conflicts, migration.version)
+			}
+			continue
+		}
+		applied[versionID] = migration.version
+
+		if versionID < highest {
+			result.OutOfOrder = append(result.OutOfOrder, migration.version)
+		} else {
+			highest = versionID
+		}
+
+		rows = append(rows, gooseVersionRow{
+			version:  versionID,
+			tstamp:   migration.installedOn,
+			desc:     migration.desc,
+			checksum: migration.checksum,
+		})
+	}
+
+	// goose 默认要求版本按执行顺序单调递增，之后遇到比当前版本低的迁移会报错
+	if len(result.OutOfOrder) > 0 {
+		if opts.StrictOrder {
+			return nil, fmt.Errorf("Flyway表 %s 中的版本 %s 在更高的版本之后才执行，goose 要求版本按执行顺序递增",
+				flywayTable, strings.Join(result.OutOfOrder, ", "))
+		}
+		getLogger().Warnf("versions %s in %s were applied after higher versions (Flyway outOfOrder); goose requires versions to be applied in increasing order, "+
+			"so run goose with allow-missing (goose.WithAllowMissing) or renumber these migrations", strings.Join(result.OutOfOrder, ", "), flywayTable)
+	}
+
+	// 4. 创建Goose版本表，表已存在时需要检查其中已有的版本
+	existing := gooseTableExists(ctx, dstDB, dstDriver, gooseTable)
+	if !existing {
+		if err := createGooseTable(ctx, dstDB, dstDriver, gooseTable, opts.PreserveChecksum); err != nil {
+			return nil, fmt.Errorf("创建Goose表失败: %w", err)
 		}
+	} else if opts.PreserveChecksum {
+		if err := ensureChecksumColumn(ctx, dstDB, dstDriver, gooseTable); err != nil {
+			return nil, err
+		}
+	}
+
+	// 5. 在同一个事务中写入所有记录，写入失败时全部回滚。上面的建表和增加 checksum 列不在事务中，
+	// MySQL 等数据库的 DDL 会隐式提交，它们不会回滚，重新执行时按已存在的表处理
+	tx, err := dstDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
 
-		// 5. 插入Goose版本表
-		err = insertGooseVersion(db, driver, gooseTable, versionID, migration.installedOn, migration.desc)
+	if existing && opts.Watermark != nil && opts.Watermark.FromGoose {
+		rows, err = filterAboveGooseVersion(ctx, tx, dstDriver, gooseTable, rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	if existing {
+		rows, err = resolveExistingVersions(ctx, tx, dstDriver, gooseTable, rows, opts.ConflictMode, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range rows {
+		if row.version != 0 {
+			result.Inserted++
+			result.Versions = append(result.Versions, row.version)
+		}
+	}
+	if err := insertGooseVersions(ctx, tx, dstDriver, gooseTable, rows, opts.PreserveChecksum, opts.BatchSize); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
 
-	return nil
+	return result, nil
 }
 
 // 表名校验（正则验证）
 func validateTableNames(tables ...string) error {
+	return validateTableNamesFor("", tables...)
+}
+
+// validateTableNamesFor 按数据库类型校验表名，Oracle 会将未加引号的表名转为大写，因此允许大写字母
+func validateTableNamesFor(driver string, tables ...string) error {
 	validPattern := regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`) // 小写字母+下划线
+	if DriverFamily(driver) == "oracle" {
+		validPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,127}$`)
+	}
 	for _, tbl := range tables {
 		if !validPattern.MatchString(tbl) {
 			return fmt.Errorf("表名 %q 不符合命名规范", tbl)
@@ -89,10 +385,60 @@ func validateTableNames(tables ...string) error {
 	return nil
 }
 
-// 动态创建Goose表
-func createGooseTable(db *sql.DB, driver, gooseTable string) error {
+// sqlExecer 是 *sql.DB 和 *sql.Tx 共有的执行接口
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// checksumColumnTypes 各数据库中 checksum 列的类型
+var checksumColumnTypes = map[string]string{
+	"mysql":     "INT",
+	"postgres":  "INTEGER",
+	"sqlite3":   "INTEGER",
+	"sqlserver": "INT",
+	"oracle":    "NUMBER(10)",
+}
+
+// gooseTableExists 用一条不返回记录的查询探测 Goose 表是否存在，查询失败时认为表不存在。
+// 各数据库(以及不同语言的)表已存在的错误信息不同，因此不通过建表的错误判断
+func gooseTableExists(ctx context.Context, db *sql.DB, driver, gooseTable string) bool {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE 1 = 0", gooseTableName(driver, gooseTable)))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// ensureChecksumColumn 在已存在的 Goose 表(如之前没有开启 PreserveChecksum 时创建的)中没有 checksum 列时加上该列
+func ensureChecksumColumn(ctx context.Context, db *sql.DB, driver, gooseTable string) error {
+	table := gooseTableName(driver, gooseTable)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT checksum FROM %s WHERE 1 = 0", table))
+	if err == nil {
+		rows.Close()
+		return nil
+	}
+
+	family := DriverFamily(driver)
+	add := "ADD COLUMN"
+	if family == "sqlserver" || family == "oracle" {
+		add = "ADD"
+	}
+	columnType, ok := checksumColumnTypes[family]
+	if !ok {
+		return fmt.Errorf("不支持的数据库类型: %s", driver)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s %s checksum %s", table, add, columnType)); err != nil {
+		return fmt.Errorf("Goose表 %s 没有 checksum 列，添加该列失败: %w", gooseTable, err)
+	}
+	return nil
+}
+
+// 动态创建Goose表，withChecksum 为 true 时增加保存 Flyway checksum 的列
+func createGooseTable(ctx context.Context, db sqlExecer, driver, gooseTable string, withChecksum bool) error {
 	var createSQL string
-	switch driver {
+	family := DriverFamily(driver)
+	switch family {
 	case "mysql":
 		createSQL = fmt.Sprintf(`CREATE TABLE %s (
       id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -101,39 +447,81 @@ func createGooseTable(db *sql.DB, driver, gooseTable string) error {
       tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
       description VARCHAR(255)
     )`, gooseTable)
-	case "postgres", "opengauss", "gaussdb", "kingbase", "pgx", "pgx/v5":
+	case "postgres":
 		createSQL = fmt.Sprintf(`CREATE TABLE %s (
       id BIGSERIAL PRIMARY KEY,
       version_id BIGINT NOT NULL,
       is_applied BOOLEAN DEFAULT TRUE NOT NULL,
       tstamp TIMESTAMPTZ DEFAULT NOW(),
       description TEXT
+    )`, gooseTable)
+	case "sqlite3":
+		createSQL = fmt.Sprintf(`CREATE TABLE %s (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      version_id INTEGER NOT NULL,
+      is_applied INTEGER NOT NULL,
+      tstamp TIMESTAMP DEFAULT (datetime('now')),
+      description TEXT
+    )`, gooseTable)
+	case "sqlserver":
+		createSQL = fmt.Sprintf(`CREATE TABLE [%s] (
+      id BIGINT IDENTITY(1,1) PRIMARY KEY,
+      version_id BIGINT NOT NULL,
+      is_applied BIT DEFAULT 1 NOT NULL,
+      tstamp DATETIME2 DEFAULT SYSUTCDATETIME(),
+      description NVARCHAR(255)
+    )`, gooseTable)
+	case "oracle":
+		createSQL = fmt.Sprintf(`CREATE TABLE %s (
+      id NUMBER(19) GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+      version_id NUMBER(19) NOT NULL,
+      is_applied NUMBER(1) DEFAULT 1 NOT NULL,
+      tstamp TIMESTAMP DEFAULT SYSTIMESTAMP,
+      description VARCHAR2(255)
     )`, gooseTable)
 	default:
 		return fmt.Errorf("不支持的数据库类型: %s", driver)
 	}
-	_, err := db.Exec(createSQL)
+	if withChecksum {
+		createSQL = strings.TrimSuffix(createSQL, "\n    )") +
+			",\n      checksum " + checksumColumnTypes[family] + "\n    )"
+	}
+	_, err := db.ExecContext(ctx, createSQL)
 	return err
 }
 
 type flywayMigrateResult struct {
-	version     string
-	desc        string
-	installedOn time.Time
+	version       string
+	desc          string
+	installedOn   time.Time
+	checksum      sql.NullInt64
+	installedRank int64
 }
 
-// 获取最新Flyway版本（安全查询）
+// 获取最新Flyway版本（安全查询），withChecksum 为 true 时同时读取 checksum 和 installed_rank
 func getAllFlywayVersions(
+	ctx context.Context,
 	db *sql.DB,
 	driver string,
 	flywayTable string,
+	withChecksum bool,
 ) ([]flywayMigrateResult, error) {
 	// 使用参数化避免SQL注入（表名已校验）
-	query := fmt.Sprintf(`SELECT version, description, installed_on 
+	// 只复制执行成功的版本化迁移，可重复执行的迁移(R__)没有版本号
+	success := "1"
+	if DriverFamily(driver) == "postgres" {
+		success = "TRUE"
+	}
+	columns := "version, description, installed_on"
+	if withChecksum {
+		columns += ", checksum, installed_rank"
+	}
+	query := fmt.Sprintf(`SELECT %s 
                           FROM %s 
-                          ORDER BY installed_on ASC`, flywayTable)
+                          WHERE success = %s AND version IS NOT NULL
+                          ORDER BY installed_on ASC`, columns, flywayTable, success)
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		if IsTableNotExists(err) {
 			return nil, sql.ErrNoRows
@@ -145,7 +533,11 @@ func getAllFlywayVersions(
 	var results []flywayMigrateResult
 	for rows.Next() {
 		var result flywayMigrateResult
-		err := rows.Scan(&result.version, &result.desc, &result.installedOn)
+		dest := []interface{}{&result.version, &result.desc, &result.installedOn}
+		if withChecksum {
+			dest = append(dest, &result.checksum, &result.installedRank)
+		}
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, err
 		}
@@ -156,37 +548,354 @@ func getAllFlywayVersions(
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+
+	// installed_on 相同时按版本号排序，保证生成的 goose 版本单调递增
+	sort.SliceStable(results, func(i, j int) bool {
+		if !results[i].installedOn.Equal(results[j].installedOn) {
+			return results[i].installedOn.Before(results[j].installedOn)
+		}
+		if results[i].installedRank != results[j].installedRank {
+			return results[i].installedRank < results[j].installedRank
+		}
+		return compareFlywayVersions(results[i].version, results[j].version) < 0
+	})
 	return results, nil
 }
 
-// 插入Goose版本记录
-func insertGooseVersion(
-	db *sql.DB,
+// compareFlywayVersions 按数值逐段比较两个 Flyway 版本号(如 1.2 < 1.10)，
+// 与 Flyway 一样缺少的段按 0 比较(1 与 1.0 相同)
+func compareFlywayVersions(a, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' })
+	}
+	partsA, partsB := split(a), split(b)
+	part := func(parts []string, i int) string {
+		if i < len(parts) {
+			return parts[i]
+		}
+		return "0"
+	}
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		numA, errA := strconv.ParseInt(part(partsA, i), 10, 64)
+		numB, errB := strconv.ParseInt(part(partsB, i), 10, 64)
+		if errA != nil || errB != nil {
+			if c := strings.Compare(part(partsA, i), part(partsB, i)); c != 0 {
+				return c
+			}
+			continue
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// gooseVersionRow 一条待写入Goose表的版本记录
+type gooseVersionRow struct {
+	version  int64
+	tstamp   time.Time
+	desc     string
+	checksum sql.NullInt64
+}
+
+// resolveExistingVersions 检查 rows 中的版本是否已存在于 Goose 表中，按 mode 跳过、报错或删除旧记录，
+// 返回需要写入的记录。version_id=0 的记录已存在时总是跳过
+func resolveExistingVersions(
+	ctx context.Context,
+	tx *sql.Tx,
 	driver string,
 	gooseTable string,
-	version int64,
-	t time.Time,
-	desc string,
-) error {
+	rows []gooseVersionRow,
+	mode ConflictMode,
+	result *CopyResult,
+) ([]gooseVersionRow, error) {
+	table := gooseTableName(driver, gooseTable)
+	var pending []gooseVersionRow
+	for _, row := range rows {
+		var exists int
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT 1 FROM %s WHERE version_id = %s`, table, bindVar(driver, 1)), row.version).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			pending = append(pending, row)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询Goose版本失败: %w", err)
+		}
+		if row.version == 0 {
+			continue
+		}
+
+		result.Existing = append(result.Existing, row.version)
+		switch mode {
+		case ConflictError:
+			return nil, fmt.Errorf("版本已存在: %d", row.version)
+		case ConflictOverwrite:
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE version_id = %s`, table, bindVar(driver, 1)), row.version)
+			if err != nil {
+				return nil, fmt.Errorf("删除失败: %w", err)
+			}
+			pending = append(pending, row)
+		default:
+			result.Skipped++
+		}
+	}
+	return pending, nil
+}
+
+// filterAboveGooseVersion 返回 rows 中版本大于 Goose 表中最大版本的记录，version_id=0 的记录交给 resolveExistingVersions 处理
+func filterAboveGooseVersion(ctx context.Context, tx *sql.Tx, driver, gooseTable string, rows []gooseVersionRow) ([]gooseVersionRow, error) {
+	var highest sql.NullInt64
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT MAX(version_id) FROM %s`, gooseTableName(driver, gooseTable))).Scan(&highest)
+	if err != nil {
+		return nil, fmt.Errorf("查询Goose最大版本失败: %w", err)
+	}
+	var pending []gooseVersionRow
+	for _, row := range rows {
+		if row.version == 0 || row.version > highest.Int64 {
+			pending = append(pending, row)
+		}
+	}
+	return pending, nil
+}
+
+// gooseTableName 返回 SQL 中使用的 Goose 表名，SQL Server 使用 [name] 形式
+func gooseTableName(driver, gooseTable string) string {
+	if DriverFamily(driver) == "sqlserver" {
+		return "[" + gooseTable + "]"
+	}
+	return gooseTable
+}
+
+// bindVar 返回第 n 个参数的占位符
+func bindVar(driver string, n int) string {
+	switch DriverFamily(driver) {
+	case "postgres":
+		return "$" + strconv.Itoa(n)
+	case "sqlserver":
+		return "@p" + strconv.Itoa(n)
+	case "oracle":
+		return ":" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// 插入Goose版本记录，batchSize 大于 1 时每条 INSERT 写入最多 batchSize 条记录
+func insertGooseVersions(ctx context.Context, db sqlExecer, driver, gooseTable string, rows []gooseVersionRow, withChecksum bool, batchSize int) error {
+	if batchSize < 1 || DriverFamily(driver) == "oracle" {
+		batchSize = 1
+	}
+
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := insertGooseVersionBatch(ctx, db, driver, gooseTable, rows[start:end], withChecksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertGooseVersionBatch 用一条 INSERT 语句写入 rows
+func insertGooseVersionBatch(ctx context.Context, db sqlExecer, driver, gooseTable string, rows []gooseVersionRow, withChecksum bool) error {
 	// 动态生成插入语句
-	var insertSQL string
-	var args []interface{}
-	switch driver {
-	case "mysql":
-		insertSQL = fmt.Sprintf(`INSERT INTO %s 
-      (version_id, is_applied, tstamp, description) 
-      VALUES (?, ?, ?, ?)`, gooseTable)
-		args = []interface{}{version, 1, t.UTC(), desc}
-	case "postgres", "opengauss", "gaussdb", "kingbase", "pgx", "pgx/v5":
-		insertSQL = fmt.Sprintf(`INSERT INTO %s 
-      (version_id, is_applied, tstamp, description) 
-      VALUES ($1, $2, $3, $4)`, gooseTable)
-		args = []interface{}{version, true, t, desc}
-	}
-
-	_, err := db.Exec(insertSQL, args...)
+	var isApplied interface{} = 1
+	utc := true
+	switch DriverFamily(driver) {
+	case "mysql", "sqlite3", "oracle":
+	case "postgres":
+		isApplied, utc = true, false
+	case "sqlserver":
+		isApplied = true
+	default:
+		return fmt.Errorf("不支持的数据库类型: %s", driver)
+	}
+
+	columns := "version_id, is_applied, tstamp, description"
+	width := 4
+	if withChecksum {
+		columns += ", checksum"
+		width++
+	}
+
+	values := make([]string, 0, len(rows))
+	args := make([]interface{}, 0, width*len(rows))
+	for _, row := range rows {
+		vars := make([]string, width)
+		for i := range vars {
+			vars[i] = bindVar(driver, len(args)+i+1)
+		}
+		values = append(values, "("+strings.Join(vars, ", ")+")")
+
+		tstamp := row.tstamp
+		if utc {
+			tstamp = tstamp.UTC()
+		}
+		args = append(args, row.version, isApplied, tstamp, row.desc)
+		if withChecksum {
+			args = append(args, row.checksum)
+		}
+	}
+
+	insertSQL := fmt.Sprintf(`INSERT INTO %s 
+      (%s) 
+      VALUES %s`, gooseTableName(driver, gooseTable), columns, strings.Join(values, ", "))
+	_, err := db.ExecContext(ctx, insertSQL, args...)
 	if err != nil {
 		return fmt.Errorf("插入失败: %w", err)
 	}
 	return nil
 }
+
+// TablePair 表示一对需要复制的 Flyway 表和 Goose 表
+type TablePair struct {
+	FlywayTable string
+	GooseTable  string
+}
+
+// CopyMigrateTables 依次复制多对 Flyway 表到 Goose 表(如多租户场景下每个租户各有一对表)
+func CopyMigrateTables(driver string, db *sql.DB, pairs []TablePair, baseYear string) error {
+	return CopyMigrateTablesParallel(driver, db, pairs, baseYear, 1)
+}
+
+// CopyMigrateTablesParallel 与 CopyMigrateTables 相同，但最多同时复制 concurrency 对表。
+// 所有表名会在复制前统一校验，各对表的错误汇总后一起返回
+func CopyMigrateTablesParallel(driver string, db *sql.DB, pairs []TablePair, baseYear string, concurrency int) error {
+	for _, pair := range pairs {
+		if err := validateTableNamesFor(driver, pair.FlywayTable, pair.GooseTable); err != nil {
+			return fmt.Errorf("表名非法: %s", err)
+		}
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(pairs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for idx, pair := range pairs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, pair TablePair) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := CopyMigrateTable(driver, db, pair.FlywayTable, pair.GooseTable, baseYear)
+			if err != nil {
+				errs[idx] = fmt.Errorf("%s -> %s: %w", pair.FlywayTable, pair.GooseTable, err)
+			}
+		}(idx, pair)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// VersionCheck 是版本表与 goose 迁移目录交叉校验的结果
+type VersionCheck struct {
+	Orphans []int64 // 版本表中有记录但目录中没有对应文件的版本
+	Gaps    []int64 // 目录中有文件但版本表中没有记录的版本
+}
+
+// CopyMigrateTableChecked 与 CopyMigrateTable 相同，复制后再用 CheckGooseVersions
+// 将 Goose 表与 gooseDir 中的迁移文件进行交叉校验
+func CopyMigrateTableChecked(
+	driver string,
+	db *sql.DB,
+	flywayTable string,
+	gooseTable string,
+	baseYear string,
+	gooseDir fs.FS,
+) (*VersionCheck, error) {
+	if err := CopyMigrateTable(driver, db, flywayTable, gooseTable, baseYear); err != nil {
+		return nil, err
+	}
+	return CheckGooseVersions(db, gooseTable, gooseDir)
+}
+
+// CheckGooseVersions 检查 Goose 表中的每个 version_id 在 gooseDir 中都有对应的迁移文件，
+// 并对孤立的记录(Orphans)和缺少记录的文件(Gaps)输出警告
+func CheckGooseVersions(db *sql.DB, gooseTable string, gooseDir fs.FS) (*VersionCheck, error) {
+	if err := validateTableNames(gooseTable); err != nil {
+		return nil, fmt.Errorf("表名非法: %s", err)
+	}
+
+	files, err := readGooseFileVersions(gooseDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取Goose迁移目录失败: %s", err)
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT version_id FROM %s WHERE version_id > 0`, gooseTable))
+	if err != nil {
+		return nil, fmt.Errorf("读取Goose版本失败: %s", err)
+	}
+	defer rows.Close()
+
+	recorded := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("读取Goose版本失败: %s", err)
+		}
+		recorded[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取Goose版本失败: %s", err)
+	}
+
+	result := &VersionCheck{}
+	for version := range recorded {
+		if _, ok := files[version]; !ok {
+			result.Orphans = append(result.Orphans, version)
+		}
+	}
+	for version := range files {
+		if !recorded[version] {
+			result.Gaps = append(result.Gaps, version)
+		}
+	}
+	sort.Slice(result.Orphans, func(i, j int) bool { return result.Orphans[i] < result.Orphans[j] })
+	sort.Slice(result.Gaps, func(i, j int) bool { return result.Gaps[i] < result.Gaps[j] })
+
+	for _, version := range result.Orphans {
+		getLogger().Warnf("version %d is recorded in %s but has no migration file", version, gooseTable)
+	}
+	for _, version := range result.Gaps {
+		getLogger().Warnf("migration file %s has no record in %s", files[version], gooseTable)
+	}
+	return result, nil
+}
+
+// gooseFilenameRE 匹配 goose 迁移文件名，如 20250101000000_init.sql
+var gooseFilenameRE = regexp.MustCompile(`^(\d+)_.*\.(sql|go)$`)
+
+// readGooseFileVersions 返回 goose 迁移目录中 版本号 -> 文件名
+func readGooseFileVersions(gooseDir fs.FS) (map[int64]string, error) {
+	entries, err := fs.ReadDir(gooseDir, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := gooseFilenameRE.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("版本号非法 %s: %s", entry.Name(), err)
+		}
+		files[version] = entry.Name()
+	}
+	return files, nil
+}
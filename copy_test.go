@@ -1,15 +1,26 @@
 package goflyway
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
 )
 
 func TestCopyMigrateTable_NormalCase(t *testing.T) {
@@ -21,10 +32,13 @@ func TestCopyMigrateTable_NormalCase(t *testing.T) {
 		AddRow("1.2.030405", "Initial schema", time.Now())
 	mock.ExpectQuery(`SELECT version, description, installed_on
                           FROM flyway_schema
+                          WHERE success = 1 AND version IS NOT NULL
                           ORDER BY installed_on ASC`).
 		WillReturnRows(flywayRow)
 
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
 	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
 
 	// 预期 Goose 表操作
 	// mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE goose_versions`)).
@@ -32,6 +46,10 @@ func TestCopyMigrateTable_NormalCase(t *testing.T) {
 	// mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE version_id = ?`).
 	// 	WithArgs(int64(20250102030405)).
 	// 	WillReturnRows(sqlmock.NewRows([]string{"exists"})) // 无冲突
+	// goose 建表时写入的 version_id=0 记录
+	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
+		WithArgs(int64(0), 1, sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
 		WithArgs(
 			int64(20250102030405), // 版本号
@@ -39,6 +57,7 @@ func TestCopyMigrateTable_NormalCase(t *testing.T) {
 			sqlmock.AnyArg(),      // tstamp (动态时间)
 			"Initial schema",      // 描述
 		).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// 执行函数
 	err := CopyMigrateTable("mysql", db, "flyway_schema", "goose_versions", "2025")
@@ -52,6 +71,782 @@ func TestCopyMigrateTable_NormalCase(t *testing.T) {
 	}
 }
 
+// TestCopyMigrateTable_MatchesGooseState 复制后的记录应与 goose 依次执行相同版本后写入的记录一致
+func TestCopyMigrateTable_MatchesGooseState(t *testing.T) {
+	// 1. 让 goose 在 sqlite 上执行两个版本，得到它写入的版本表
+	migrationsDir := t.TempDir()
+	for _, name := range []string{"20250101000001_a.sql", "20250102000003_b.sql"} {
+		err := os.WriteFile(filepath.Join(migrationsDir, name), []byte("-- +goose Up\nSELECT 1;\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gooseDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gooseDB.Close()
+	gooseDB.SetMaxOpenConns(1)
+
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(gooseDB, migrationsDir); err != nil {
+		t.Fatalf("goose.Up() error = %v", err)
+	}
+
+	rows, err := gooseDB.Query("SELECT version_id, is_applied FROM goose_db_version ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type gooseRow struct {
+		versionID int64
+		isApplied bool
+	}
+	var expected []gooseRow
+	for rows.Next() {
+		var r gooseRow
+		if err := rows.Scan(&r.versionID, &r.isApplied); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, r)
+	}
+	rows.Close()
+
+	// 2. 复制包含相同版本（其中一个版本重复出现）的 Flyway 历史，
+	//    期望写入的记录与 goose 的记录逐条一致
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT version, description, installed_on
+                          FROM flyway_schema
+                          WHERE success = 1 AND version IS NOT NULL
+                          ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1.1", "a", now).
+			AddRow("1.2.3", "b", now.Add(time.Second)).
+			AddRow("1.2.3", "b", now.Add(2*time.Second)))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	for _, r := range expected {
+		if !r.isApplied {
+			t.Fatalf("goose wrote a non-applied row for version %d", r.versionID)
+		}
+		mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
+			WithArgs(r.versionID, 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if err := CopyMigrateTable("mysql", db, "flyway_schema", "goose_versions", "2025"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("copied rows differ from goose state: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_SQLite 在内存 sqlite 数据库上复制版本表
+func TestCopyMigrateTable_SQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_schema VALUES ('1.1.1', 'a', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_schema VALUES ('1.2.3', 'b', '2024-01-02 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CopyMigrateTable("sqlite3", db, "flyway_schema", "goose_versions", "2025"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+
+	rows, err := db.Query("SELECT id, version_id, is_applied FROM goose_versions ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var id, versionID, isApplied int64
+		if err := rows.Scan(&id, &versionID, &isApplied); err != nil {
+			t.Fatal(err)
+		}
+		if isApplied != 1 {
+			t.Errorf("version %d is_applied = %d, want 1", versionID, isApplied)
+		}
+		versions = append(versions, versionID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{0, 20250101000001, 20250102000003}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("goose versions = %v, want %v", versions, want)
+	}
+}
+
+// TestCopyMigrateTable_OnlySuccessfulVersions 执行失败的迁移和没有版本号的可重复迁移不复制
+func TestCopyMigrateTable_OnlySuccessfulVersions(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_schema VALUES ('1.1', 'ok', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_schema VALUES (NULL, 'repeatable', '2024-01-02 00:00:00', 1)`,
+		`INSERT INTO flyway_schema VALUES ('1.2', 'failed', '2024-01-03 00:00:00', 0)`,
+		`INSERT INTO flyway_schema VALUES ('1.3', 'ok', '2024-01-04 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := CopyMigrateTableWithResult("sqlite3", db, "flyway_schema", "goose_versions", "2000")
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if result.Inserted != 2 {
+		t.Errorf("Inserted = %d, want 2", result.Inserted)
+	}
+
+	rows, err := db.Query("SELECT version_id FROM goose_versions ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+
+	want := []int64{0, 20000101000000, 20000103000000}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("goose versions = %v, want %v", versions, want)
+	}
+}
+
+// TestCopyMigrateTable_OutOfOrder 测试 Flyway outOfOrder 模式下的执行顺序与版本顺序不一致
+func TestCopyMigrateTable_OutOfOrder(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_schema VALUES ('1.1', 'a', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_schema VALUES ('1.3', 'c', '2024-01-02 00:00:00', 1)`,
+		`INSERT INTO flyway_schema VALUES ('1.2', 'b', '2024-01-03 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "strict_versions", "2000", CopyOptions{StrictOrder: true})
+	if err == nil || !strings.Contains(err.Error(), "1.2") {
+		t.Fatalf("CopyMigrateTable() error = %v, want out-of-order error", err)
+	}
+
+	var logs bytes.Buffer
+	SetLogger(NewStdLogger(log.New(&logs, "", 0)))
+	defer SetLogger(nil)
+
+	result, err := CopyMigrateTableWithResult("sqlite3", db, "flyway_schema", "goose_versions", "2000")
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.OutOfOrder, []string{"1.2"}) {
+		t.Errorf("OutOfOrder = %v, want [1.2]", result.OutOfOrder)
+	}
+	if !strings.Contains(logs.String(), "versions 1.2 in flyway_schema were applied after higher versions") {
+		t.Errorf("expected out-of-order warning, got %q", logs.String())
+	}
+}
+
+// TestCopyMigrateTable_VersionIDColumnBits 测试版本超出 32 位 version_id 列时不写入任何记录
+func TestCopyMigrateTable_VersionIDColumnBits(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = TRUE AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+
+	_, err := CopyMigrateTableWithOptions("postgres", db, "flyway_schema", "goose_versions", "2000", CopyOptions{VersionIDColumnBits: 32})
+	if err == nil || !strings.Contains(err.Error(), "32-bit") {
+		t.Errorf("CopyMigrateTableWithOptions() error = %v, want 32-bit overflow error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_VersionStrategy 测试按 CopyOptions.VersionStrategy 转换版本号
+func TestCopyMigrateTable_VersionStrategy(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = TRUE AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("20230115103000", "a", installedOn).
+			AddRow("1.1", "b", installedOn))
+
+	_, err := CopyMigrateTableWithOptions("postgres", db, "flyway_schema", "goose_versions", "2000", CopyOptions{VersionStrategy: VersionStrategyTimestamp})
+	if err == nil || !strings.Contains(err.Error(), `version "1.1" is not a 14-digit timestamp`) {
+		t.Errorf("CopyMigrateTableWithOptions() error = %v, want timestamp strategy error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_DriverAlias 测试驱动别名(如 pgx)按对应的数据库类型建表和写入
+func TestCopyMigrateTable_DriverAlias(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = TRUE AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGSERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN DEFAULT TRUE NOT NULL, tstamp TIMESTAMPTZ DEFAULT NOW(), description TEXT )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	for _, args := range [][]driver.Value{
+		{int64(0), true, installedOn, ""},
+		{int64(20000101000000), true, installedOn, "a"},
+	} {
+		mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+			WithArgs(args...).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if _, err := CopyMigrateTableWithOptions("pgx", db, "flyway_schema", "goose_versions", "2000", CopyOptions{}); err != nil {
+		t.Fatalf("CopyMigrateTableWithOptions() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_Batched 测试 CopyOptions.BatchSize 大于 1 时使用多行 INSERT
+func TestCopyMigrateTable_Batched(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = TRUE AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn).
+			AddRow("1.2", "b", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGSERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN DEFAULT TRUE NOT NULL, tstamp TIMESTAMPTZ DEFAULT NOW(), description TEXT )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)`).
+		WithArgs(int64(0), true, installedOn, "", int64(20000101000000), true, installedOn, "a").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+		WithArgs(int64(20000102000000), true, installedOn, "b").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if _, err := CopyMigrateTableWithOptions("postgres", db, "flyway_schema", "goose_versions", "2000", CopyOptions{BatchSize: 2}); err != nil {
+		t.Fatalf("CopyMigrateTableWithOptions() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_RollbackOnError 写入失败时回滚整个复制
+func TestCopyMigrateTable_RollbackOnError(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
+		WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	if err := CopyMigrateTable("mysql", db, "flyway_schema", "goose_versions", "2000"); err == nil {
+		t.Fatal("CopyMigrateTable() expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTableContext_Canceled 测试已取消的 ctx 会中止复制
+func TestCopyMigrateTableContext_Canceled(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := CopyMigrateTableContext(ctx, "mysql", db, "flyway_schema", "goose_versions", "2000")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CopyMigrateTableContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_PreserveChecksum 测试保存 Flyway 的 checksum
+func TestCopyMigrateTable_PreserveChecksum(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema (installed_rank INTEGER, version TEXT, description TEXT, installed_on TIMESTAMP, checksum INTEGER, success BOOLEAN)`,
+		`INSERT INTO flyway_schema VALUES (2, '1.2', 'b', '2024-01-01 00:00:00', NULL, 1)`,
+		`INSERT INTO flyway_schema VALUES (1, '1.1', 'a', '2024-01-01 00:00:00', -1234567, 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "goose_versions", "2000", CopyOptions{PreserveChecksum: true})
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+
+	rows, err := db.Query("SELECT version_id, checksum FROM goose_versions ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var version int64
+		var checksum sql.NullInt64
+		if err := rows.Scan(&version, &checksum); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%v", version, checksum.Int64))
+		if version == 20000102000000 && checksum.Valid {
+			t.Errorf("checksum of %d = %v, want NULL", version, checksum.Int64)
+		}
+	}
+	want := []string{"0:0", "20000101000000:-1234567", "20000102000000:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("goose rows = %v, want %v", got, want)
+	}
+
+	// 未开启时 Goose 表没有 checksum 列
+	if err := CopyMigrateTable("sqlite3", db, "flyway_schema", "vanilla_versions", "2000"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if _, err := db.Query("SELECT checksum FROM vanilla_versions"); err == nil {
+		t.Error("vanilla goose table should not have a checksum column")
+	}
+
+	// 之后开启时给已存在的 Goose 表加上 checksum 列
+	if _, err := db.Exec(`INSERT INTO flyway_schema VALUES (3, '1.3', 'c', '2024-01-02 00:00:00', 42, 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "vanilla_versions", "2000", CopyOptions{PreserveChecksum: true}); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	var checksum sql.NullInt64
+	if err := db.QueryRow("SELECT checksum FROM vanilla_versions WHERE version_id = 20000103000000").Scan(&checksum); err != nil {
+		t.Fatal(err)
+	}
+	if !checksum.Valid || checksum.Int64 != 42 {
+		t.Errorf("checksum of 20000103000000 = %v, want 42", checksum)
+	}
+}
+
+// TestCopyMigrateTable_Watermark 测试按水位增量复制
+func TestCopyMigrateTable_Watermark(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	exec := func(stmts ...string) {
+		t.Helper()
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	gooseVersions := func(table string) []int64 {
+		t.Helper()
+		rows, err := db.Query("SELECT version_id FROM " + table + " ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var versions []int64
+		for rows.Next() {
+			var version int64
+			if err := rows.Scan(&version); err != nil {
+				t.Fatal(err)
+			}
+			versions = append(versions, version)
+		}
+		return versions
+	}
+
+	exec(`CREATE TABLE flyway_schema (installed_rank INTEGER, version TEXT, description TEXT, installed_on TIMESTAMP, checksum INTEGER, success BOOLEAN)`,
+		`INSERT INTO flyway_schema VALUES (1, '1.1', 'a', '2024-01-01 00:00:00', NULL, 1)`,
+		`INSERT INTO flyway_schema VALUES (2, '1.2', 'b', '2024-01-02 00:00:00', NULL, 1)`)
+
+	first, err := CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "goose_versions", "2000",
+		CopyOptions{Watermark: &CopyWatermark{}})
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if first.LastInstalledRank != 2 {
+		t.Errorf("LastInstalledRank = %d, want 2", first.LastInstalledRank)
+	}
+
+	// Flyway 继续执行了新的迁移，第二次只复制水位之后的记录
+	exec(`INSERT INTO flyway_schema VALUES (3, '1.3', 'c', '2024-01-03 00:00:00', NULL, 1)`,
+		`INSERT INTO flyway_schema VALUES (4, '1.4', 'd', '2024-01-04 00:00:00', NULL, 1)`)
+
+	second, err := CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "goose_versions", "2000",
+		CopyOptions{Watermark: &CopyWatermark{InstalledRank: first.LastInstalledRank}})
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if want := []int64{20000103000000, 20000104000000}; !reflect.DeepEqual(second.Versions, want) {
+		t.Errorf("Versions = %v, want %v", second.Versions, want)
+	}
+	if len(second.Existing) != 0 {
+		t.Errorf("Existing = %v, want none", second.Existing)
+	}
+	if second.LastInstalledRank != 4 {
+		t.Errorf("LastInstalledRank = %d, want 4", second.LastInstalledRank)
+	}
+
+	want := []int64{0, 20000101000000, 20000102000000, 20000103000000, 20000104000000}
+	if got := gooseVersions("goose_versions"); !reflect.DeepEqual(got, want) {
+		t.Errorf("goose rows = %v, want %v", got, want)
+	}
+
+	// 从 Goose 表的最大版本推导水位
+	exec(`INSERT INTO flyway_schema VALUES (5, '1.5', 'e', '2024-01-05 00:00:00', NULL, 1)`)
+	third, err := CopyMigrateTableWithOptions("sqlite3", db, "flyway_schema", "goose_versions", "2000",
+		CopyOptions{Watermark: &CopyWatermark{FromGoose: true}})
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if want := []int64{20000105000000}; !reflect.DeepEqual(third.Versions, want) || len(third.Existing) != 0 {
+		t.Errorf("Versions = %v, Existing = %v, want %v and none", third.Versions, third.Existing, want)
+	}
+	want = append(want, 20000105000000)
+	if got := gooseVersions("goose_versions"); !reflect.DeepEqual(got, want) {
+		t.Errorf("goose rows = %v, want %v", got, want)
+	}
+}
+
+// TestCopyMigrateTable_Oracle 测试 Oracle 的建表语句、:1 形式的参数和大写表名
+func TestCopyMigrateTable_Oracle(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM FLYWAY_SCHEMA_HISTORY WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM GOOSE_DB_VERSION WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE GOOSE_DB_VERSION ( id NUMBER(19) GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, version_id NUMBER(19) NOT NULL, is_applied NUMBER(1) DEFAULT 1 NOT NULL, tstamp TIMESTAMP DEFAULT SYSTIMESTAMP, description VARCHAR2(255) )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	for _, version := range []int64{0, 20000101000000} {
+		mock.ExpectExec(`INSERT INTO GOOSE_DB_VERSION (version_id, is_applied, tstamp, description) VALUES (:1, :2, :3, :4)`).
+			WithArgs(version, 1, installedOn, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if err := CopyMigrateTable("oracle", db, "FLYWAY_SCHEMA_HISTORY", "GOOSE_DB_VERSION", "2000"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+
+	// 其它数据库仍然只接受小写表名
+	if err := CopyMigrateTable("mysql", db, "FLYWAY_SCHEMA_HISTORY", "GOOSE_DB_VERSION", "2000"); err == nil {
+		t.Error("CopyMigrateTable() expected error for uppercase table names on mysql")
+	}
+}
+
+// TestCopyMigrateTable_SQLServer 测试 SQL Server 的建表语句和 @p1 形式的参数
+func TestCopyMigrateTable_SQLServer(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema_history WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM [goose_db_version] WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE [goose_db_version] ( id BIGINT IDENTITY(1,1) PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BIT DEFAULT 1 NOT NULL, tstamp DATETIME2 DEFAULT SYSUTCDATETIME(), description NVARCHAR(255) )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	for _, version := range []int64{0, 20000101000000} {
+		mock.ExpectExec(`INSERT INTO [goose_db_version] (version_id, is_applied, tstamp, description) VALUES (@p1, @p2, @p3, @p4)`).
+			WithArgs(version, true, installedOn, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if err := CopyMigrateTable("sqlserver", db, "flyway_schema_history", "goose_db_version", "2000"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTable_SQLServerExisting 测试通过查询探测到 Goose 表已存在时不再建表，不依赖建表的错误信息
+func TestCopyMigrateTable_SQLServerExisting(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema_history WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM [goose_db_version] WHERE 1 = 0`).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT 1 FROM [goose_db_version] WHERE version_id = @p1`).WithArgs(int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectQuery(`SELECT 1 FROM [goose_db_version] WHERE version_id = @p1`).WithArgs(int64(20000101000000)).
+		WillReturnRows(sqlmock.NewRows([]string{"1"}))
+	mock.ExpectExec(`INSERT INTO [goose_db_version] (version_id, is_applied, tstamp, description) VALUES (@p1, @p2, @p3, @p4)`).
+		WithArgs(int64(20000101000000), true, installedOn, "a").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := CopyMigrateTable("sqlserver", db, "flyway_schema_history", "goose_db_version", "2000"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+// TestCopyMigrateTableAcross 测试从 MySQL 读取 Flyway 表并写入 Postgres 的 Goose 表
+func TestCopyMigrateTableAcross(t *testing.T) {
+	srcDB, srcMock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer srcDB.Close()
+	dstDB, dstMock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer dstDB.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	srcMock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema_history WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.1", "a", installedOn))
+
+	dstMock.ExpectQuery(`SELECT 1 FROM goose_db_version WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	dstMock.ExpectExec(`CREATE TABLE goose_db_version ( id BIGSERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN DEFAULT TRUE NOT NULL, tstamp TIMESTAMPTZ DEFAULT NOW(), description TEXT )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dstMock.ExpectBegin()
+	for _, version := range []int64{0, 20000101000000} {
+		dstMock.ExpectExec(`INSERT INTO goose_db_version (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+			WithArgs(version, true, installedOn, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	dstMock.ExpectCommit()
+
+	if err := CopyMigrateTableAcross("mysql", srcDB, "postgres", dstDB, "flyway_schema_history", "goose_db_version", "2000"); err != nil {
+		t.Fatalf("CopyMigrateTableAcross() error = %v", err)
+	}
+	if err := srcMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的源数据库预期: %v", err)
+	}
+	if err := dstMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的目标数据库预期: %v", err)
+	}
+
+	// 表名按各自的数据库类型校验
+	if err := CopyMigrateTableAcross("oracle", srcDB, "mysql", dstDB, "FLYWAY_SCHEMA_HISTORY", "GOOSE_DB_VERSION", "2000"); err == nil {
+		t.Error("CopyMigrateTableAcross() expected error for uppercase goose table name on mysql")
+	}
+}
+
+// TestCopyMigrateTable_TiedInstalledOn installed_on 相同时按版本号顺序写入
+func TestCopyMigrateTable_TiedInstalledOn(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = 1 AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1.10", "c", installedOn).
+			AddRow("1.2", "b", installedOn).
+			AddRow("1.1", "a", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+
+	for _, version := range []int64{0, 20000101000000, 20000102000000, 20000110000000} {
+		mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES (?, ?, ?, ?)`).
+			WithArgs(version, 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	if err := CopyMigrateTable("mysql", db, "flyway_schema", "goose_versions", "2000"); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+func TestCompareFlywayVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2", "1.10", -1},
+		{"1.10", "1.2", 1},
+		{"1.2", "1.2", 0},
+		{"1.2", "1.2.1", -1},
+		{"1_3", "1.2", 1},
+	}
+	for _, tt := range tests {
+		got := compareFlywayVersions(tt.a, tt.b)
+		if (got < 0 && tt.expected >= 0) || (got > 0 && tt.expected <= 0) || (got == 0 && tt.expected != 0) {
+			t.Errorf("compareFlywayVersions(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestCopyMigrateTables(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	now := time.Now()
+	pairs := []TablePair{
+		{FlywayTable: "tenant_a_flyway", GooseTable: "tenant_a_goose"},
+		{FlywayTable: "tenant_b_flyway", GooseTable: "tenant_b_goose"},
+	}
+	for i, pair := range pairs {
+		mock.ExpectQuery(`SELECT version, description, installed_on
+                          FROM ` + pair.FlywayTable + `
+                          WHERE success = TRUE AND version IS NOT NULL
+                          ORDER BY installed_on ASC`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+				AddRow("1.1."+strconv.Itoa(i+1), "init", now))
+		mock.ExpectQuery(`SELECT 1 FROM ` + pair.GooseTable + ` WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+		mock.ExpectExec(`CREATE TABLE ` + pair.GooseTable + ` ( id BIGSERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN DEFAULT TRUE NOT NULL, tstamp TIMESTAMPTZ DEFAULT NOW(), description TEXT )`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO `+pair.GooseTable+` (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+			WithArgs(int64(0), true, sqlmock.AnyArg(), "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO `+pair.GooseTable+` (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+			WithArgs(int64(20250101000001+i), true, sqlmock.AnyArg(), "init").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	if err := CopyMigrateTablesParallel("postgres", db, pairs, "2025", 2); err != nil {
+		t.Fatalf("CopyMigrateTablesParallel() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
+
+func TestCopyMigrateTables_ValidatesAllNamesFirst(t *testing.T) {
+	pairs := []TablePair{
+		{FlywayTable: "tenant_a_flyway", GooseTable: "tenant_a_goose"},
+		{FlywayTable: "tenant_b_flyway", GooseTable: "tenant_b;goose"},
+	}
+	// db 为 nil，若在校验前访问数据库会直接 panic
+	err := CopyMigrateTables("postgres", nil, pairs, "2025")
+	if err == nil || !strings.Contains(err.Error(), "表名非法") {
+		t.Errorf("未拒绝非法表名: %v", err)
+	}
+}
+
+func TestCopyMigrateTables_AggregatesErrors(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	for _, table := range []string{"tenant_a_flyway", "tenant_b_flyway"} {
+		mock.ExpectQuery(`SELECT version, description, installed_on
+                          FROM ` + table + `
+                          WHERE success = TRUE AND version IS NOT NULL
+                          ORDER BY installed_on ASC`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}))
+	}
+
+	err := CopyMigrateTables("postgres", db, []TablePair{
+		{FlywayTable: "tenant_a_flyway", GooseTable: "tenant_a_goose"},
+		{FlywayTable: "tenant_b_flyway", GooseTable: "tenant_b_goose"},
+	}, "2025")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, table := range []string{"tenant_a_flyway -> tenant_a_goose", "tenant_b_flyway -> tenant_b_goose"} {
+		if !strings.Contains(err.Error(), table) {
+			t.Errorf("error missing pair %q: %v", table, err)
+		}
+	}
+}
+
 func TestInvalidTableNames(t *testing.T) {
 	invalidTables := []string{"", "flyway!history", "goose;DROP TABLE users;"}
 	for _, table := range invalidTables {
@@ -64,31 +859,90 @@ func TestInvalidTableNames(t *testing.T) {
 	}
 }
 
-// func TestVersionConflict(t *testing.T) {
-// 	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
-// 	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).WillReturnResult(sqlmock.NewResult(1, 1))
+func TestVersionConflict(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_tbl (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_tbl VALUES ('1.1', 'a', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_tbl VALUES ('1.2', 'b', '2024-01-02 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	countRows := func() int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM goose_tbl").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if err := CopyMigrateTable("sqlite3", db, "flyway_tbl", "goose_tbl", "2025"); err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
 
-// 	// 模拟 Flyway 返回有效版本
-// 	mock.ExpectQuery(`SELECT version, description, installed_on`).
-// 		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("2025.01.01.000000"))
-// 	// 模拟 Goose 表已存在该版本
-// 	mock.ExpectQuery(`SELECT 1 FROM goose_versions`).
-// 		WithArgs(int64(20250101000000)).
-// 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(1))
+	// 默认跳过已存在的版本，重复执行不会写入重复记录
+	result, err := CopyMigrateTableWithResult("sqlite3", db, "flyway_tbl", "goose_tbl", "2025")
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if result.Inserted != 0 || result.Skipped != 2 || len(result.Existing) != 2 {
+		t.Errorf("result = %+v, want 2 existing versions skipped", result)
+	}
+	if n := countRows(); n != 3 {
+		t.Errorf("goose_tbl has %d rows, want 3", n)
+	}
 
-// 	err := CopyMigrateTable("postgres", db, "flyway_tbl", "goose_tbl", "2025")
-// 	if err == nil || !strings.Contains(err.Error(), "版本已存在") {
-// 		t.Error("未检测到版本冲突")
-// 	}
-// }
+	// 新增的版本照常写入
+	if _, err := db.Exec(`INSERT INTO flyway_tbl VALUES ('1.3', 'c', '2024-01-03 00:00:00', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	_, err = CopyMigrateTableWithOptions("sqlite3", db, "flyway_tbl", "goose_tbl", "2025", CopyOptions{ConflictMode: ConflictError})
+	if err == nil || !strings.Contains(err.Error(), "版本已存在") {
+		t.Errorf("未检测到版本冲突: %v", err)
+	}
+	if n := countRows(); n != 3 {
+		t.Errorf("goose_tbl has %d rows after conflict error, want 3", n)
+	}
+
+	if _, err := db.Exec(`UPDATE flyway_tbl SET description = 'renamed' WHERE version = '1.1'`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = CopyMigrateTableWithOptions("sqlite3", db, "flyway_tbl", "goose_tbl", "2025", CopyOptions{ConflictMode: ConflictOverwrite})
+	if err != nil {
+		t.Fatalf("CopyMigrateTable() error = %v", err)
+	}
+	if result.Inserted != 3 || len(result.Existing) != 2 {
+		t.Errorf("result = %+v, want 3 versions written with 2 overwritten", result)
+	}
+	if n := countRows(); n != 4 {
+		t.Errorf("goose_tbl has %d rows, want 4", n)
+	}
+	var desc string
+	if err := db.QueryRow("SELECT description FROM goose_tbl WHERE version_id = 20250101000000").Scan(&desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc != "renamed" {
+		t.Errorf("description = %q, want renamed", desc)
+	}
+}
 
 func TestEmptyFlywayTable(t *testing.T) {
 	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	mock.ExpectQuery(`SELECT version, description, installed_on
                           FROM flyway_history
+                          WHERE success = 1 AND version IS NOT NULL
                           ORDER BY installed_on ASC`).
 		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"})) // 空结果集
 
+	mock.ExpectQuery(`SELECT 1 FROM goose_ver WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
 	mock.ExpectExec(`CREATE TABLE goose_ver ( id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied TINYINT DEFAULT 1 NOT NULL, -- 默认标记为已应用 tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP, description VARCHAR(255) )`).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := CopyMigrateTable("mysql", db, "flyway_history", "goose_ver", "2025")
@@ -247,3 +1101,53 @@ func queryGooseData(t *testing.T, db *sql.DB) []GooseRecord {
 	}
 	return records
 }
+
+func TestCopyMigrateTableChecked(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	installedOn := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT version, description, installed_on FROM flyway_schema WHERE success = TRUE AND version IS NOT NULL ORDER BY installed_on ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "description", "installed_on"}).
+			AddRow("1", "init", installedOn).
+			AddRow("1.2.3", "orphan", installedOn))
+	mock.ExpectQuery(`SELECT 1 FROM goose_versions WHERE 1 = 0`).WillReturnError(errors.New("no such table"))
+	mock.ExpectExec(`CREATE TABLE goose_versions ( id BIGSERIAL PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN DEFAULT TRUE NOT NULL, tstamp TIMESTAMPTZ DEFAULT NOW(), description TEXT )`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	for _, args := range [][]driver.Value{
+		{int64(0), true, installedOn, ""},
+		{int64(20000101000000), true, installedOn, "init"},
+		{int64(20000102000003), true, installedOn, "orphan"},
+	} {
+		mock.ExpectExec(`INSERT INTO goose_versions (version_id, is_applied, tstamp, description) VALUES ($1, $2, $3, $4)`).
+			WithArgs(args...).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT version_id FROM goose_versions WHERE version_id > 0`).
+		WillReturnRows(sqlmock.NewRows([]string{"version_id"}).
+			AddRow(int64(20000101000000)).
+			AddRow(int64(20000102000003)))
+
+	// 20000102000003 没有对应文件，20000201000001 没有版本记录
+	gooseDir := fstest.MapFS{
+		"20000101000000_init.sql":  {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"20000201000001_later.sql": {Data: []byte("-- +goose Up\nSELECT 2;\n")},
+		"README.md":                {Data: []byte("not a migration")},
+	}
+
+	result, err := CopyMigrateTableChecked("postgres", db, "flyway_schema", "goose_versions", "2000", gooseDir)
+	if err != nil {
+		t.Fatalf("CopyMigrateTableChecked() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.Orphans, []int64{20000102000003}) {
+		t.Errorf("Orphans = %v, want [20000102000003]", result.Orphans)
+	}
+	if !reflect.DeepEqual(result.Gaps, []int64{20000201000001}) {
+		t.Errorf("Gaps = %v, want [20000201000001]", result.Gaps)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的数据库预期: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package goflyway
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CutoverReport 汇总一次切换(转换迁移脚本并复制版本表)的结果，可以直接序列化为 JSON
+type CutoverReport struct {
+	Conversion *ConversionReport `json:"conversion"`
+	Copy       *CopyResult       `json:"copy"`
+}
+
+// Cutover 将 cfg.InputPath 中的 Flyway 迁移脚本转换到 cfg.OutputDir，
+// 再将 flywayTable 中的版本记录复制到 gooseTable，之后数据库就可以交给 goose 管理
+func Cutover(cfg *Config, db *sql.DB, flywayTable, gooseTable string) (_ *CutoverReport, err error) {
+	var audit *RunAudit
+	if cfg.AuditFile != "" {
+		audit = newRunAudit("cutover", cfg)
+		defer func() {
+			if auditErr := audit.finish(cfg.AuditFile, err); auditErr != nil && err == nil {
+				err = auditErr
+			}
+		}()
+	}
+
+	conversion, err := convertWithReport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert migrations: %w", err)
+	}
+	if audit != nil {
+		audit.Conversion = conversion
+	}
+
+	copied, err := CopyMigrateTableWithOptions(cfg.DBDriver, db, flywayTable, gooseTable, cfg.BaseYear, CopyOptions{
+		PreserveChecksum:    cfg.PreserveChecksum,
+		VersionStrategy:     cfg.VersionStrategy,
+		VersionScheme:       cfg.VersionScheme,
+		VersionIDColumnBits: cfg.VersionIDColumnBits,
+		Numbering:           conversion.FlywayVersions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy migration history: %w", err)
+	}
+	if audit != nil {
+		audit.Copy = copied
+	}
+
+	return &CutoverReport{
+		Conversion: conversion,
+		Copy:       copied,
+	}, nil
+}
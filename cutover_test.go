@@ -0,0 +1,105 @@
+package goflyway
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCutover(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema_history (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_schema_history VALUES ('1', 'first migration', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_schema_history VALUES ('1.2.3', 'second migration', '2024-01-02 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &Config{
+		InputPath: "testdata",
+		OutputDir: t.TempDir(),
+		BaseYear:  "2000",
+		DBDriver:  "sqlite3",
+	}
+	report, err := Cutover(cfg, db, "flyway_schema_history", "goose_db_version")
+	if err != nil {
+		t.Fatalf("Cutover() error = %v", err)
+	}
+
+	written := []ConvertedFile{
+		{Source: "V1.2.3__second_migration.sql", Target: "20000102000003_second_migration.sql", Version: 20000102000003},
+		{Source: "V1__first_migration.sql", Target: "20000101000000_first_migration.sql", Version: 20000101000000},
+	}
+	if !reflect.DeepEqual(report.Conversion.Written, written) {
+		t.Errorf("Conversion.Written = %v, want %v", report.Conversion.Written, written)
+	}
+	if report.Copy.Inserted != 2 {
+		t.Errorf("Copy.Inserted = %d, want 2", report.Copy.Inserted)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var decoded map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded["conversion"]["written"] == nil || decoded["copy"]["inserted"] != float64(2) {
+		t.Errorf("unexpected JSON report: %s", data)
+	}
+}
+
+// TestCutover_Stride 测试重新编号时复制到 goose 表的版本与转换后的文件一致
+func TestCutover_Stride(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE flyway_schema_history (version TEXT, description TEXT, installed_on TIMESTAMP, success BOOLEAN)`,
+		`INSERT INTO flyway_schema_history VALUES ('1', 'first migration', '2024-01-01 00:00:00', 1)`,
+		`INSERT INTO flyway_schema_history VALUES ('1.2.3', 'second migration', '2024-01-02 00:00:00', 1)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &Config{
+		InputPath:      "testdata",
+		OutputDir:      t.TempDir(),
+		BaseYear:       "2000",
+		DBDriver:       "sqlite3",
+		GooseNumbering: GooseNumberingStride,
+	}
+	report, err := Cutover(cfg, db, "flyway_schema_history", "goose_db_version")
+	if err != nil {
+		t.Fatalf("Cutover() error = %v", err)
+	}
+	if want := []int64{10, 20}; !reflect.DeepEqual(report.Copy.Versions, want) {
+		t.Errorf("Copy.Versions = %v, want %v", report.Copy.Versions, want)
+	}
+
+	// Flyway 表中的版本没有对应的迁移脚本时报错
+	if _, err := db.Exec(`INSERT INTO flyway_schema_history VALUES ('3', 'missing', '2024-01-03 00:00:00', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	cfg.OutputDir = t.TempDir()
+	if _, err := Cutover(cfg, db, "flyway_schema_history", "goose_tbl"); err == nil {
+		t.Error("Cutover() expected error for a version without a migration")
+	}
+}
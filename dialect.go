@@ -0,0 +1,116 @@
+package goflyway
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// driverFamilies 驱动名 -> 数据库类型
+var driverFamilies = map[string]string{
+	"postgres":  "postgres",
+	"opengauss": "postgres",
+	"gaussdb":   "postgres",
+	"kingbase":  "postgres",
+	"pgx":       "postgres",
+	"pgx/v5":    "postgres",
+	"mysql":     "mysql",
+	"sqlite":    "sqlite3",
+	"sqlite3":   "sqlite3",
+	"sqlserver": "sqlserver",
+	"mssql":     "sqlserver",
+	"oracle":    "oracle",
+	"godror":    "oracle",
+}
+
+// DriverFamily 返回驱动名对应的数据库类型(postgres、mysql、sqlite3、sqlserver、oracle)，未知时返回空字符串
+func DriverFamily(driver string) string {
+	return driverFamilies[strings.ToLower(driver)]
+}
+
+// gooseDialect 返回驱动名对应的 goose 方言名，如 mssql 对应 sqlserver，未知的驱动原样返回
+func gooseDialect(driver string) string {
+	if family := DriverFamily(driver); family != "" && family != "oracle" {
+		return family
+	}
+	return driver
+}
+
+// dialectProbes 依次尝试的版本查询，parse 根据查询结果判断数据库类型
+var dialectProbes = []struct {
+	query string
+	parse func(version string) string
+}{
+	{
+		query: "SELECT version()",
+		parse: func(version string) string {
+			lower := strings.ToLower(version)
+			switch {
+			case strings.Contains(lower, "postgresql"),
+				strings.Contains(lower, "opengauss"),
+				strings.Contains(lower, "gaussdb"),
+				strings.Contains(lower, "kingbase"):
+				return "postgres"
+			case strings.Contains(lower, "mysql"),
+				strings.Contains(lower, "mariadb"),
+				version != "" && version[0] >= '0' && version[0] <= '9':
+				// MySQL 的 version() 只返回 "8.0.33" 这样的版本号
+				return "mysql"
+			}
+			return ""
+		},
+	},
+	{
+		query: "SELECT sqlite_version()",
+		parse: func(version string) string { return "sqlite3" },
+	},
+	{
+		query: "SELECT @@VERSION",
+		parse: func(version string) string {
+			if strings.Contains(strings.ToLower(version), "sql server") {
+				return "sqlserver"
+			}
+			return ""
+		},
+	},
+	{
+		query: "SELECT banner FROM v$version",
+		parse: func(version string) string {
+			if strings.Contains(strings.ToLower(version), "oracle") {
+				return "oracle"
+			}
+			return ""
+		},
+	},
+}
+
+// DetectDialect 通过查询数据库版本判断 db 实际连接的数据库类型，返回值同 DriverFamily
+func DetectDialect(db *sql.DB) (string, error) {
+	var errs []error
+	for _, probe := range dialectProbes {
+		var version string
+		if err := db.QueryRow(probe.query).Scan(&version); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", probe.query, err))
+			continue
+		}
+		if family := probe.parse(version); family != "" {
+			return family, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: unrecognized version %q", probe.query, version))
+	}
+	return "", fmt.Errorf("failed to detect database dialect: %w", errors.Join(errs...))
+}
+
+// CheckDialect 检测 db 实际连接的数据库类型，与 driver 不一致时输出警告并返回检测到的类型，
+// 用于在执行任何 DDL 之前发现驱动名与连接串不匹配的问题
+func CheckDialect(db *sql.DB, driver string) (string, error) {
+	detected, err := DetectDialect(db)
+	if err != nil {
+		return "", err
+	}
+	if expected := DriverFamily(driver); expected != "" && expected != detected {
+		getLogger().Warnf("driver %q expects a %s database but connected to %s", driver, expected, detected)
+	}
+	return detected, nil
+}
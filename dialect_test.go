@@ -0,0 +1,156 @@
+package goflyway
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDetectDialect(t *testing.T) {
+	probeErr := errors.New("syntax error")
+
+	tests := []struct {
+		name     string
+		setup    func(mock sqlmock.Sqlmock)
+		expected string
+	}{
+		{
+			name: "PostgreSQL",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("PostgreSQL 15.4 on x86_64-pc-linux-gnu, compiled by gcc"))
+			},
+			expected: "postgres",
+		},
+		{
+			name: "openGauss",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("(openGauss 5.0.0 build a07d57c3) compiled at 2023-03-29"))
+			},
+			expected: "postgres",
+		},
+		{
+			name: "KingbaseES",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("KingbaseES V008R006C007B0024 on x86_64-pc-linux-gnu"))
+			},
+			expected: "postgres",
+		},
+		{
+			name: "MySQL",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("8.0.33"))
+			},
+			expected: "mysql",
+		},
+		{
+			name: "MariaDB",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("10.11.4-MariaDB-1:10.11.4+maria~ubu2204"))
+			},
+			expected: "mysql",
+		},
+		{
+			name: "SQLite",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT sqlite_version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("3.45.1"))
+			},
+			expected: "sqlite3",
+		},
+		{
+			name: "SQL Server",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT sqlite_version()").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT @@VERSION").WillReturnRows(sqlmock.NewRows([]string{"version"}).
+					AddRow("Microsoft SQL Server 2022 (RTM) - 16.0.1000.6 (X64)"))
+			},
+			expected: "sqlserver",
+		},
+		{
+			name: "Oracle",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT version()").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT sqlite_version()").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT @@VERSION").WillReturnError(probeErr)
+				mock.ExpectQuery("SELECT banner FROM v$version").WillReturnRows(sqlmock.NewRows([]string{"banner"}).
+					AddRow("Oracle Database 19c Enterprise Edition Release 19.0.0.0.0 - Production"))
+			},
+			expected: "oracle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			tt.setup(mock)
+
+			got, err := DetectDialect(db)
+			if err != nil {
+				t.Fatalf("DetectDialect() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("DetectDialect() = %v, want %v", got, tt.expected)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("未满足的数据库预期: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectDialect_Unknown(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	probeErr := errors.New("syntax error")
+	mock.ExpectQuery("SELECT version()").WillReturnError(probeErr)
+	mock.ExpectQuery("SELECT sqlite_version()").WillReturnError(probeErr)
+	mock.ExpectQuery("SELECT @@VERSION").WillReturnError(probeErr)
+	mock.ExpectQuery("SELECT banner FROM v$version").WillReturnError(probeErr)
+
+	if _, err := DetectDialect(db); err == nil {
+		t.Error("expected error when no probe succeeds")
+	}
+}
+
+func TestCheckDialect_Mismatch(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("8.0.33"))
+
+	// postgres 驱动连接到了 MySQL
+	detected, err := CheckDialect(db, "postgres")
+	if err != nil {
+		t.Fatalf("CheckDialect() error = %v", err)
+	}
+	if detected != "mysql" {
+		t.Errorf("CheckDialect() = %v, want mysql", detected)
+	}
+}
+
+func TestGooseDialect(t *testing.T) {
+	tests := map[string]string{
+		"sqlserver":  "sqlserver",
+		"mssql":      "sqlserver",
+		"pgx":        "postgres",
+		"sqlite":     "sqlite3",
+		"mysql":      "mysql",
+		"oracle":     "oracle",
+		"clickhouse": "clickhouse",
+	}
+	for driver, want := range tests {
+		if got := gooseDialect(driver); got != want {
+			t.Errorf("gooseDialect(%q) = %v, want %v", driver, got, want)
+		}
+	}
+}
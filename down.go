@@ -0,0 +1,174 @@
+package goflyway
+
+import (
+	"strings"
+)
+
+// GenerateDown 根据 Up 语句推断对应的 Down 语句。
+// Down 语句按 Up 语句的相反顺序排列；返回的 bool 表示是否所有语句都能安全地反转，
+// 为 false 时返回的内容只包含能反转的那部分语句。
+func GenerateDown(upSQL string) (string, bool, error) {
+	statements, err := Split(strings.NewReader(upSQL))
+	if err != nil {
+		return "", false, err
+	}
+
+	complete := true
+	var downs []string
+	for idx := len(statements) - 1; idx >= 0; idx-- {
+		if isEmptyOrComments(statements[idx]) {
+			continue
+		}
+		down, err := reverseStatement(statements[idx])
+		if err != nil {
+			return "", false, err
+		}
+		if down == "" {
+			complete = false
+			continue
+		}
+		downs = append(downs, down)
+	}
+
+	if len(downs) == 0 {
+		return "", false, nil
+	}
+	return strings.Join(downs, "\n"), complete, nil
+}
+
+// reverseStatement 返回能撤销 stmt 的语句，无法安全反转时返回空字符串。支持:
+//
+//	CREATE TABLE/INDEX/VIEW/MATERIALIZED VIEW/SEQUENCE/SCHEMA x -> DROP ... x
+//	ALTER TABLE t ADD [COLUMN] c ...                         -> ALTER TABLE t DROP COLUMN c
+//	ALTER TABLE t ADD CONSTRAINT c ...                       -> ALTER TABLE t DROP CONSTRAINT c
+func reverseStatement(stmt string) (string, error) {
+	tokens, err := significantTokens(stmt)
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) < 3 {
+		return "", nil
+	}
+
+	switch strings.ToUpper(tokens[0]) {
+	case "CREATE":
+		return reverseCreate(tokens), nil
+	case "ALTER":
+		return reverseAlterTable(tokens), nil
+	}
+	return "", nil
+}
+
+func reverseCreate(tokens []string) string {
+	pos := 1
+	var kind string
+	switch strings.ToUpper(tokens[pos]) {
+	case "OR":
+		// CREATE OR REPLACE 会覆盖已有定义，无法还原
+		return ""
+	case "UNIQUE":
+		pos++
+	case "MATERIALIZED":
+		kind = "MATERIALIZED "
+		pos++
+	}
+	if pos >= len(tokens) {
+		return ""
+	}
+
+	switch word := strings.ToUpper(tokens[pos]); word {
+	case "TABLE", "INDEX", "VIEW", "SEQUENCE", "SCHEMA":
+		kind += word
+	default:
+		return ""
+	}
+	pos++
+
+	pos = skipWords(tokens, pos, "CONCURRENTLY")
+	pos = skipWords(tokens, pos, "IF", "NOT", "EXISTS")
+
+	name, _ := rawQualifiedName(tokens, pos)
+	if name == "" || strings.EqualFold(name, "ON") {
+		return ""
+	}
+	return "DROP " + kind + " " + name + ";"
+}
+
+func reverseAlterTable(tokens []string) string {
+	if !strings.EqualFold(tokens[1], "TABLE") {
+		return ""
+	}
+	pos := skipWords(tokens, 2, "IF", "EXISTS")
+	pos = skipWords(tokens, pos, "ONLY")
+
+	table, pos := rawQualifiedName(tokens, pos)
+	if table == "" || pos >= len(tokens) || !strings.EqualFold(tokens[pos], "ADD") {
+		return ""
+	}
+	pos++
+
+	// 多个子句时不做反转
+	if hasTopLevelComma(tokens[pos:]) {
+		return ""
+	}
+
+	target := "COLUMN"
+	if pos < len(tokens) && strings.EqualFold(tokens[pos], "CONSTRAINT") {
+		target = "CONSTRAINT"
+		pos++
+	} else {
+		pos = skipWords(tokens, pos, "COLUMN")
+		pos = skipWords(tokens, pos, "IF", "NOT", "EXISTS")
+	}
+	if pos >= len(tokens) {
+		return ""
+	}
+
+	name := tokens[pos]
+	if _, ok := unquoteIdentifier(name); !ok {
+		return ""
+	}
+	switch strings.ToUpper(name) {
+	case "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "INDEX", "KEY":
+		// 没有名字的约束
+		return ""
+	}
+	return "ALTER TABLE " + table + " DROP " + target + " " + name + ";"
+}
+
+// rawQualifiedName 读取 name 或 schema.name 形式的标识符，保留原始的引号
+func rawQualifiedName(tokens []string, pos int) (string, int) {
+	var sb strings.Builder
+	for pos < len(tokens) {
+		if _, ok := unquoteIdentifier(tokens[pos]); !ok {
+			break
+		}
+		sb.WriteString(tokens[pos])
+		pos++
+
+		if pos+1 >= len(tokens) || tokens[pos] != "." {
+			break
+		}
+		sb.WriteString(".")
+		pos++
+	}
+	return sb.String(), pos
+}
+
+// hasTopLevelComma 检查括号外是否有逗号
+func hasTopLevelComma(tokens []string) bool {
+	depth := 0
+	for _, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package goflyway
+
+import (
+	"testing"
+)
+
+func TestGenerateDown(t *testing.T) {
+	tests := []struct {
+		name     string
+		up       string
+		down     string
+		complete bool
+	}{
+		{
+			name: "可完全反转",
+			up: `-- 建表
+CREATE TABLE IF NOT EXISTS app.users (id INT, name VARCHAR(20));
+CREATE UNIQUE INDEX idx_users_name ON app.users (name);
+ALTER TABLE app.users ADD COLUMN email VARCHAR(100);
+ALTER TABLE app.users ADD CONSTRAINT fk_org FOREIGN KEY (org_id) REFERENCES orgs (id);
+CREATE MATERIALIZED VIEW "User View" AS SELECT * FROM app.users;`,
+			down: `DROP MATERIALIZED VIEW "User View";
+ALTER TABLE app.users DROP CONSTRAINT fk_org;
+ALTER TABLE app.users DROP COLUMN email;
+DROP INDEX idx_users_name;
+DROP TABLE app.users;`,
+			complete: true,
+		},
+		{
+			name: "部分语句无法反转",
+			up: `CREATE TABLE t (id INT);
+INSERT INTO t VALUES (1);
+CREATE OR REPLACE VIEW v AS SELECT id FROM t;`,
+			down:     `DROP TABLE t;`,
+			complete: false,
+		},
+		{
+			name: "多个子句和匿名对象",
+			up: `ALTER TABLE t ADD COLUMN a INT, ADD COLUMN b INT;
+ALTER TABLE t ADD PRIMARY KEY (id);
+CREATE INDEX ON t (a);`,
+			down:     "",
+			complete: false,
+		},
+		{
+			name: "DROP 语句",
+			up:   `DROP TABLE t;`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			down, complete, err := GenerateDown(tt.up)
+			if err != nil {
+				t.Fatalf("GenerateDown() error = %v", err)
+			}
+			if complete != tt.complete {
+				t.Errorf("GenerateDown() complete = %v, want %v", complete, tt.complete)
+			}
+			if down != tt.down {
+				t.Errorf("GenerateDown() mismatch:\nExpected:\n%s\nGot:\n%s", tt.down, down)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package goflyway
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+)
+
+// GooseEmbedFile Config.EmbedPackage 不为空时在输出目录中生成的 Go 文件名
+const GooseEmbedFile = "migrations_embed.go"
+
+// gooseEmbedTemplate 生成的 Go 文件，%s 为包名
+const gooseEmbedTemplate = `// Code generated by flyway2goose. DO NOT EDIT.
+
+package %s
+
+import (
+	"embed"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Migrations 包含本目录中所有的 goose 迁移文件
+//
+//go:embed *.sql
+var Migrations embed.FS
+
+func init() {
+	goose.SetBaseFS(Migrations)
+}
+`
+
+// gooseYearEmbedTemplate 迁移文件在年份子目录中时生成的 Go 文件，%s 为包名
+const gooseYearEmbedTemplate = `// Code generated by flyway2goose. DO NOT EDIT.
+
+package %s
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Migrations 包含本目录的年份子目录中所有的 goose 迁移文件
+//
+//go:embed */*.sql
+var Migrations embed.FS
+
+// yearFS 将年份子目录中的迁移文件作为同一个目录中的文件提供给 goose
+type yearFS struct {
+	embed.FS
+}
+
+// Glob 在所有子目录中匹配，goose 按文件名中的版本号排序，与所在的子目录无关
+func (f yearFS) Glob(pattern string) ([]string, error) {
+	dir, file := path.Split(pattern)
+	return fs.Glob(f.FS, path.Join(dir, "*", file))
+}
+
+func init() {
+	goose.SetBaseFS(yearFS{Migrations})
+}
+`
+
+// writeEmbedFile 在 outputDir 中生成使用 go:embed 打包迁移文件并通过 goose.SetBaseFS 注册的 Go 文件，
+// 导入该包后可直接调用 goose.Up(db, ".")。yearDirs 为 true 时迁移文件在年份子目录中
+func writeEmbedFile(outputDir, pkg string, yearDirs bool) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid embed package name %q", pkg)
+	}
+
+	outputPath := filepath.Join(outputDir, GooseEmbedFile)
+	template := gooseEmbedTemplate
+	if yearDirs {
+		template = gooseYearEmbedTemplate
+	}
+	if err := os.WriteFile(outputPath, []byte(fmt.Sprintf(template, pkg)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package goflyway
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProcessFS_EmbedPackage(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	outputDir := t.TempDir()
+
+	testFS := fstest.MapFS{
+		"V1__init.sql": {Data: []byte("CREATE TABLE a (id INT);\n")},
+	}
+	if err := processFS(testFS, outputDir, &Config{BaseYear: "2000", EmbedPackage: "migrations"}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, GooseEmbedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "package migrations") {
+		t.Errorf("unexpected embed file:\n%s", content)
+	}
+
+	buildGeneratedPackage(t, goBin, outputDir)
+}
+
+// buildGeneratedPackage 编译 dir 中生成的代码。dir 不在本模块中，因此生成一个只依赖本模块所用 goose 版本的 go.mod
+func buildGeneratedPackage(t *testing.T, goBin, dir string) {
+	t.Helper()
+
+	version, err := exec.Command(goBin, "list", "-m", "-f", "{{.Version}}", "github.com/pressly/goose/v3").Output()
+	if err != nil {
+		t.Fatalf("go list error = %v", err)
+	}
+	gomod := "module embedtest\n\ngo 1.24.0\n\nrequire github.com/pressly/goose/v3 " + strings.TrimSpace(string(version)) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gosum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), gosum, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(goBin, "build", "-mod=mod", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go build error = %v\n%s", err, output)
+	}
+}
+
+func TestWriteEmbedFile_InvalidPackage(t *testing.T) {
+	if err := writeEmbedFile(t.TempDir(), "my-migrations", false); err == nil {
+		t.Error("writeEmbedFile() expected error for invalid package name")
+	}
+}
+
+// TestProcessFS_YearDirectories 测试按年份把生成的文件放到子目录中
+func TestProcessFS_YearDirectories(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	outputDir := t.TempDir()
+
+	testFS := fstest.MapFS{
+		"V1__init.sql":              {Data: []byte("CREATE TABLE a (id INT);\n")},
+		"V20210315000000__seed.sql": {Data: []byte("INSERT INTO a VALUES (1);\n")},
+		"sub/V1.2__add_name.sql":    {Data: []byte("ALTER TABLE a ADD COLUMN name TEXT;\n")},
+	}
+	if err := processFS(testFS, outputDir, &Config{BaseYear: "2020", EmbedPackage: "migrations", YearDirectories: true}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	for _, name := range []string{
+		"2020/20200101000000_init.sql",
+		"2020/20200102000000_add_name.sql",
+		"2021/20210315000000_seed.sql",
+	} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+
+	buildGeneratedPackage(t, goBin, outputDir)
+
+	if err := processFS(testFS, t.TempDir(), &Config{BaseYear: "2020", YearDirectories: true, PreserveDirectories: true}); err == nil {
+		t.Error("processFS() expected error for year and preserved directories")
+	}
+}
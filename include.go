@@ -0,0 +1,77 @@
+package goflyway
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/dimchansky/utfbom"
+)
+
+// readWithIncludes 读取 name 的内容，并将其中的 include 指令替换为被引用文件的内容。
+// directives 是 include 指令的行前缀，如 "@@" 或 "-- include:"，
+// 被引用的文件相对于当前文件所在目录在 fsys 中查找。
+func readWithIncludes(fsys fs.FS, name string, directives []string) (string, error) {
+	return resolveIncludes(fsys, name, directives, nil)
+}
+
+func resolveIncludes(fsys fs.FS, name string, directives []string, stack []string) (string, error) {
+	for _, included := range stack {
+		if included == name {
+			return "", fmt.Errorf("include cycle detected: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	stack = append(stack, name)
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	var result strings.Builder
+	reader := bufio.NewReader(utfbom.SkipOnly(file))
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if target, ok := parseIncludeDirective(line, directives); ok {
+				content, err := resolveIncludes(fsys, path.Join(path.Dir(name), target), directives, stack)
+				if err != nil {
+					return "", err
+				}
+				result.WriteString(content)
+				if !strings.HasSuffix(content, "\n") {
+					result.WriteString("\n")
+				}
+			} else {
+				result.WriteString(line)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+	return result.String(), nil
+}
+
+// parseIncludeDirective 判断一行是否为 include 指令，是则返回被引用的文件路径
+func parseIncludeDirective(line string, directives []string) (string, bool) {
+	line = strings.TrimSpace(line)
+	for _, directive := range directives {
+		if directive == "" || !strings.HasPrefix(line, directive) {
+			continue
+		}
+		target := strings.TrimSpace(strings.TrimPrefix(line, directive))
+		target = strings.TrimSuffix(target, ";")
+		if target != "" {
+			return target, true
+		}
+	}
+	return "", false
+}
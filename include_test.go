@@ -0,0 +1,55 @@
+package goflyway
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProcessFS_Includes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1__init.sql":      {Data: []byte("CREATE TABLE a (id INT);\n-- include: common/grants.sql\n@@common/seed.sql\n")},
+		"common/grants.sql": {Data: []byte("GRANT SELECT ON a TO reader;\n")},
+		"common/seed.sql":   {Data: []byte("INSERT INTO a VALUES (1);")},
+	}
+
+	outputDir := t.TempDir()
+	cfg := &Config{
+		BaseYear:          "2000",
+		IncludeDirectives: []string{"-- include:", "@@"},
+	}
+	if err := processFS(fsys, outputDir, cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "20000101000000_init.sql"))
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	for _, expected := range []string{
+		"CREATE TABLE a (id INT);",
+		"GRANT SELECT ON a TO reader;",
+		"INSERT INTO a VALUES (1);",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("output missing %q:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "include:") || strings.Contains(string(content), "@@") {
+		t.Errorf("include directive not resolved:\n%s", content)
+	}
+}
+
+func TestReadWithIncludes_Cycle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"V1__a.sql": {Data: []byte("@@b.sql\n")},
+		"b.sql":     {Data: []byte("@@V1__a.sql\n")},
+	}
+
+	_, err := readWithIncludes(fsys, "V1__a.sql", []string{"@@"})
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+}
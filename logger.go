@@ -0,0 +1,74 @@
+package goflyway
+
+import (
+	"log"
+	"sync"
+)
+
+// Logger 输出转换和迁移过程中的调试信息、进度和警告
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+
+var (
+	loggerMu      sync.RWMutex
+	packageLogger Logger = nopLogger{}
+)
+
+// SetLogger 设置包级别的 Logger，Config.Logger 为 nil 时使用它，l 为 nil 时不输出任何内容(默认)
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	loggerMu.Lock()
+	packageLogger = l
+	loggerMu.Unlock()
+}
+
+// getLogger 返回包级别的 Logger
+func getLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return packageLogger
+}
+
+// logger 返回 cfg.Logger，为 nil 时返回包级别的 Logger
+func (cfg *Config) logger() Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return getLogger()
+}
+
+// stdLogger 用标准库的 *log.Logger 输出，调试信息和警告分别加上 "DEBUG: " 和 "WARNING: " 前缀
+type stdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger 返回使用 l 输出的 Logger，l 为 nil 时使用 log.Default()
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l: l}
+}
+
+func (s stdLogger) Debugf(format string, args ...interface{}) {
+	s.l.Printf("DEBUG: "+format, args...)
+}
+
+func (s stdLogger) Infof(format string, args ...interface{}) {
+	s.l.Printf(format, args...)
+}
+
+func (s stdLogger) Warnf(format string, args ...interface{}) {
+	s.l.Printf("WARNING: "+format, args...)
+}
@@ -0,0 +1,66 @@
+package goflyway
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLogger(t *testing.T) {
+	testFS := fstest.MapFS{
+		"V1__init.sql":   {Data: []byte("CREATE TABLE a (id INT);\n")},
+		"V2__readme.txt": {Data: []byte("notes\n")},
+	}
+
+	// 默认不输出任何内容
+	var std bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&std)
+	if err := processFS(testFS, t.TempDir(), &Config{BaseYear: "2000"}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if std.Len() != 0 {
+		t.Errorf("default logger should be silent, got:\n%s", std.String())
+	}
+
+	// Config.Logger 优先于包级别的 Logger
+	var pkgLogs, cfgLogs bytes.Buffer
+	SetLogger(NewStdLogger(log.New(&pkgLogs, "", 0)))
+	defer SetLogger(nil)
+	cfg := &Config{BaseYear: "2000", Logger: NewStdLogger(log.New(&cfgLogs, "", 0))}
+	if err := processFS(testFS, t.TempDir(), cfg); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if pkgLogs.Len() != 0 {
+		t.Errorf("package logger should not be used when Config.Logger is set, got:\n%s", pkgLogs.String())
+	}
+	for _, want := range []string{
+		"Converted: V1__init.sql -> 20000101000000_init.sql",
+		"DEBUG: skipping V2__readme.txt: not a .sql file",
+	} {
+		if !strings.Contains(cfgLogs.String(), want) {
+			t.Errorf("log missing %q, got:\n%s", want, cfgLogs.String())
+		}
+	}
+
+	// 分割语句时的警告也输出到 Config.Logger
+	cfgLogs.Reset()
+	if _, err := SplitWithConfig(strings.NewReader("-- +goose StatementBegin\nSELECT 1;\n"), cfg); err != nil {
+		t.Fatalf("SplitWithConfig() error = %v", err)
+	}
+	if want := "WARNING: saw '-- +goose StatementBegin' with no matching '-- +goose StatementEnd'"; !strings.Contains(cfgLogs.String(), want) {
+		t.Errorf("log missing %q, got:\n%s", want, cfgLogs.String())
+	}
+	if pkgLogs.Len() != 0 {
+		t.Errorf("package logger should not be used when Config.Logger is set, got:\n%s", pkgLogs.String())
+	}
+
+	if err := processFS(testFS, t.TempDir(), &Config{BaseYear: "2000"}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	if !strings.Contains(pkgLogs.String(), "Converted: V1__init.sql") {
+		t.Errorf("package logger not used, got:\n%s", pkgLogs.String())
+	}
+}
@@ -2,14 +2,20 @@ package goflyway
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
 	"flag"
 	"fmt"
 	"io"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dimchansky/utfbom"
 	"github.com/pressly/goose/v3"
@@ -21,42 +27,567 @@ type Config struct {
 	OutputDir    string
 	DBDriver     string
 	DBConnString string
+
+	// CanonicalizeWhitespace 为 true 时合并语句中连续的空格/制表符并去除行尾空白，
+	// 字符串和注释中的内容保持不变
+	CanonicalizeWhitespace bool
+
+	// AnnotateStatements 为 true 时在每条语句前加上 "-- stmt 3 of V1.2__x.sql" 这样的注释
+	// (Down 部分为 "-- down stmt 1 of ...")，执行出错时可以找到对应的源文件和语句。只包含注释的语句不计数
+	AnnotateStatements bool
+
+	// IncludeDirectives 为 include 指令的行前缀(如 "@@" 或 "-- include:")，
+	// 非空时在转换前将被引用文件的内容内联到迁移脚本中
+	IncludeDirectives []string
+
+	// EmitChecksums 为 true 时在输出目录中生成 .goose-checksums 文件，
+	// 记录每个生成文件的内容哈希
+	EmitChecksums bool
+
+	// EmitFlywayChecksum 为 true 时在生成文件的开头加上 "-- flyway-checksum: <值>" 注释，
+	// 值按 Flyway 的算法由源文件计算，源文件没有修改过时与 flyway_schema_history 中的 checksum 相同，
+	// 便于核对数据库中的历史记录和文件
+	EmitFlywayChecksum bool
+
+	// EmitSourceComment 为 true 时在生成文件的开头加上 "-- source: V1.2.34__create_users.sql (version 1.2.34)" 注释，
+	// 记录对应的 Flyway 文件(在输入中的相对路径)和原来的版本号。只在按文件转换时有效，ConvertFlywayToGoose 不知道文件名
+	EmitSourceComment bool
+
+	// StripStatements 为需要从每个文件开头和结尾去掉的语句(如 "SET ROLE app;")，
+	// 比较时忽略大小写、多余空白、注释和结尾分号
+	StripStatements []string
+
+	// StripStatementFunc 返回 true 时，对应的开头或结尾语句会被去掉
+	StripStatementFunc func(stmt string) bool
+
+	// SqlHandleHooks 和 SqlHandleHooksEx 为本次转换使用的语句处理钩子，与同名的全局变量含义相同。
+	// 都为 nil 时使用全局变量，否则忽略全局变量，不同的转换可以并发使用不同的钩子
+	SqlHandleHooks   []func(string) (string, error)
+	SqlHandleHooksEx []func(ctx StatementContext, stmt string) (string, error)
+
+	// UnwrappedStatementKinds 为始终不加 StatementBegin/StatementEnd 的语句类型
+	// (见 ClassifyStatement，如 "CREATE TABLE"、"INSERT")，其它语句仍按是否有内部分号决定
+	UnwrappedStatementKinds []string
+
+	// StripPsqlMeta 为 true 时去掉脚本开头的 psql 元命令(\set、\connect、\i 等)，
+	// 为 false 时保留它们并输出警告
+	StripPsqlMeta bool
+
+	// ShowDown 为 true 时在转换每个文件后打印由 GenerateDown 推断出的 Down 语句
+	ShowDown bool
+
+	// GooseVersion 为目标 goose 的版本(如 "v2"、"v3")，用于调整注解前后的空行，
+	// 为空时使用当前 v3 的格式
+	GooseVersion string
+
+	// DescriptionTransform 用于自定义生成文件名中的描述部分(如加上从文件名中提取的工单号)，
+	// 参数为文件名中分隔符之后的原始描述，返回值只能包含字母、数字和 '_'，为空或含有其它字符时转换报错
+	DescriptionTransform func(raw string) string
+
+	// NameOrder 为文件名中版本号和描述的先后顺序，默认为 VersionFirst(V1.2__desc.sql)
+	NameOrder NameOrder
+
+	// Prefix 为版本化迁移脚本的文件名前缀(对应 flyway.sqlMigrationPrefix)，为空时使用 "V"
+	Prefix string
+
+	// Separator 为文件名中版本号和描述之间的分隔符(对应 flyway.sqlMigrationSeparator)，为空时使用 "__"。
+	// VersionFirst 时在第一个分隔符处拆分，DescriptionFirst 时在最后一个分隔符处拆分，
+	// 因此分隔符为 "_" 时描述中可以有下划线，但版本号中不能有
+	Separator string
+
+	// VersionStrategy 为 Flyway 版本号转换为 Goose 版本号的方式，取值为
+	// VersionStrategyAuto(默认)、VersionStrategySemantic 或 VersionStrategyTimestamp
+	VersionStrategy string
+
+	// VersionScheme 为语义化版本号的段数、每段的取值范围、在 goose 版本号中占的位数以及超出范围时的处理方式，
+	// 为 nil 时使用 DefaultVersionScheme；有多于 3 段的版本或某一段超出 DefaultVersionScheme 的范围时使用 OrderedVersionScheme
+	VersionScheme *VersionScheme
+
+	// GenerateDown 为 true 时，对没有 undo 脚本且所有语句都能安全反转的文件，
+	// 用 GenerateDown 推断出的语句作为 Down 部分
+	GenerateDown bool
+
+	// DownStub 为没有 Down 语句的迁移的 Down 部分的内容，为空时使用 DefaultDownStub。
+	// 可以是注释，也可以是 SELECT 'no-op'; 这样的语句，使 goose down 不会遇到空的 Down 部分；
+	// 其中的 {source} 替换为 Flyway 文件路径
+	DownStub string
+
+	// SplitStatements 为 true 时将每个 Flyway 文件中的每条语句转换为单独的 goose 迁移，
+	// goose 版本为文件的版本后加上 4 位的序号(如 200001000000000001)，失败时只需要重新执行失败的语句
+	SplitStatements bool
+
+	// StatementStats 大于 0 时，转换完成后输出所有文件中字节数最大的 StatementStats 条语句及其行数
+	StatementStats int
+
+	// WarnMissingSemicolon 为 true 时对没有以分号结尾的语句输出警告(包括转换时自动补上分号的最后一条语句)，
+	// 以及语句中间没有缩进的 CREATE、INSERT 等关键字(前一条语句很可能缺少分号)，
+	// 警告中包含文件名和行号，以便修改源文件
+	WarnMissingSemicolon bool
+
+	// RollbackDir 为存放回滚脚本的目录(不能在输入目录中)，其中的文件与迁移脚本使用相同的命名规则，
+	// 按版本号与迁移配对后作为 Down 部分；同一版本同时有 U 文件时使用 U 文件，都没有时仍生成 Down 的占位注释
+	RollbackDir string
+
+	// WrapTransaction 为 true 时用 BEGIN;/COMMIT;(按 DBDriver 选择对应的语句)包住每个文件的 Up 部分，
+	// 使生成的文件也能交给不管理事务的普通 SQL 执行工具使用，标记了 NO TRANSACTION 的文件除外
+	WrapTransaction bool
+
+	// SingleTransaction 为 true 时 ConvertAndMigrate 在同一个事务中执行所有待执行的迁移，
+	// 任何语句失败时全部回滚；MySQL 等不能回滚 DDL 的数据库会直接返回错误
+	SingleTransaction bool
+
+	// MixedDDLCheck 控制 DBDriver 为 MySQL 时对同时包含 DDL 和 DML 的迁移的处理：
+	// 为空时不检查，StrictnessWarn 输出警告，StrictnessError 返回错误。
+	// MySQL 的 DDL 会隐式提交，这样的迁移中途失败后无法回滚
+	MixedDDLCheck string
+
+	// DryRun 为 true 时只计算每个文件转换后的文件名并打印，不写出任何文件，
+	// 同时标记出转换后文件名重复的文件
+	DryRun bool
+
+	// Resume 为 true 时，输出目录中已存在且内容哈希与本次转换结果一致的文件不再重新写出，
+	// 用于中断后继续转换；源文件有变化(哈希不一致)时仍会重新写出
+	Resume bool
+
+	// MaxStatementBytes 单条语句的最大字节数，超过时认为存在未闭合的引号、dollar-quote 或注释而报错，
+	// 为 0 时使用 DefaultMaxStatementBytes
+	MaxStatementBytes int
+
+	// FlywayDirectives 指定 "-- flyway:name=value" 形式的 Flyway 指令注释如何处理：
+	// 键为小写的指令名，值为替换后的注释(%s 为指令的值)，值为空时去掉该行，未列出的指令原样保留。
+	// 为 nil 时使用 DefaultFlywayDirectives，普通注释以及字符串和 dollar-quote 中的内容不受影响
+	FlywayDirectives map[string]string
+
+	// EmbedPackage 不为空时在输出目录生成以它为包名的 migrations_embed.go，
+	// 用 go:embed 将生成的迁移文件编译进程序并注册到 goose.SetBaseFS
+	EmbedPackage string
+
+	// KeywordCase 为转换时 SQL 关键字的大小写，默认为 KeywordCasePreserve(保持原样)，
+	// 标识符、字符串、注释和 $$ 代码块不受影响
+	KeywordCase KeywordCase
+
+	// SchemaRewrites 替换语句中 schema.name 形式的 schema 前缀，键和值为 schema 名，可以带结尾的 "."，
+	// 如 {"public.": "app."}；值为空时去掉前缀(如 {"dbo": ""})。未加引号的 schema 名比较时不区分大小写，
+	// 字符串和注释中的内容不会被替换
+	SchemaRewrites map[string]string
+
+	// AuditFile 不为空时，ConvertAndMigrate 和 Cutover 将本次执行的输入、选项、转换的文件、
+	// 复制的版本和最终的数据库版本以 JSON 写入该文件(如 run.json)，连接串中的密码会被隐去
+	AuditFile string
+
+	// SchemaFile 为 Baseline 使用的数据库结构文件(如 pg_dump --schema-only 的输出)，
+	// 为空时 Postgres 数据库用 PgDumpCommand 读取
+	SchemaFile string
+
+	// DependencyCheck 为 true 时，检查转换后按 goose 版本排序的迁移中，是否有迁移引用了
+	// 排在它后面的迁移才创建的对象(如版本号编码导致顺序变化)，有则输出警告。只是启发式检查
+	DependencyCheck bool
+
+	// Dialects 不为空时检查每个迁移是否使用了其中某些数据库不支持的语法(如 Postgres 不支持 AUTO_INCREMENT，
+	// MySQL 不支持 SERIAL)，发现时输出警告并记录到 ConversionReport.DialectIssues 中，不会修改迁移。
+	// 支持 mysql、postgres 和 sqlite3，见 CheckPortability
+	Dialects []string
+
+	// PreserveChecksum 为 true 时，Cutover 复制版本表时在 Goose 表中增加 checksum 列，
+	// 保存 Flyway 记录的 checksum，见 CopyOptions.PreserveChecksum
+	PreserveChecksum bool
+
+	// DropEmptyStatements 为 true 时，转换时丢弃 Split 产生的空语句、只有分号的语句和只有注释的语句
+	// (goose 注解除外)，避免 goose 执行空语句。默认为 false，保留这些内容以便与原文件一致
+	DropEmptyStatements bool
+
+	// NestedComments 为 true 时分割和转换语句时支持 /* /* */ */ 这样嵌套的块注释(PostgreSQL、SQL Server 等)，
+	// 否则块注释在第一个 */ 处结束
+	NestedComments bool
+
+	// TargetSchema 不为空时在每个转换后文件的 Up 部分开头加上切换 schema 的语句，
+	// Postgres 为 SET search_path TO <schema>，MySQL 为 USE <db>，语句按 DBDriver 生成。
+	// Up 部分结尾再切换回 RestoreSchema，使 goose 把版本记录写到原来的版本表中
+	TargetSchema string
+
+	// RestoreSchema 为 TargetSchema 时 Up 部分结尾切换回的 schema(MySQL 和 SQL Server 为 goose 版本表所在的数据库)，
+	// 为空时 Postgres 恢复切换前的 search_path，Oracle 恢复为登录用户的 schema，MySQL 和 SQL Server 必须指定
+	RestoreSchema string
+
+	// Placeholders 为 ${name} 形式的 Flyway 占位符的值，不为 nil 时替换脚本中的占位符，
+	// 没有对应值的占位符会报错。也可以用 "flyway:user" 这样的键覆盖内置占位符的值
+	Placeholders map[string]string
+
+	// DisableBuiltinPlaceholders 为 true 时不替换 ${flyway:filename}、${flyway:timestamp}、
+	// ${flyway:user}、${flyway:database} 和 ${flyway:defaultSchema} 等内置占位符
+	DisableBuiltinPlaceholders bool
+
+	// PlaceholderDefaultSeparator 不为空时占位符可以带默认值，如为 ":-" 时 ${schema:-public}
+	// 在 schema 没有对应的值时替换为 public。为空时不支持默认值
+	PlaceholderDefaultSeparator string
+
+	// PlaceholderTime 为 ${flyway:timestamp} 使用的时间，为零值时使用本次转换开始的时间。
+	// 需要每次转换的结果相同时应设置为固定的值
+	PlaceholderTime time.Time
+
+	// FilenameCollision 为两个 Flyway 文件转换出相同 goose 文件名时的处理方式：
+	// 为空或 FilenameCollisionError 时返回错误，FilenameCollisionSuffix 时在后一个文件名的描述后加上 _2、_3 等后缀。
+	// FilenameCollisionSuffix 只能与重新编号(GooseNumberingSequential/GooseNumberingStride)一起使用，保证 goose 版本不重复
+	FilenameCollision string
+
+	// Locations 为输入中需要转换的子目录(如 db/migration、db/specific)，按顺序遍历，
+	// 为空时遍历整个输入。不同目录中转换出相同文件名的迁移会按 FilenameCollision 处理
+	Locations []string
+
+	// PreserveDirectories 为 true 时在输出目录中保留迁移脚本在输入中的相对目录，
+	// 否则所有文件都写到输出目录下
+	PreserveDirectories bool
+
+	// YearDirectories 为 true 时按 goose 版本号的年份(前 4 位)把生成的文件放到输出目录的子目录中(2020/、2021/ ...)，
+	// 只是为了便于浏览，重名检查仍然针对所有文件。goose 要求迁移文件在同一个目录中，
+	// 应与 EmbedPackage 一起使用，生成的 Go 文件会把子目录中的文件作为一个目录提供给 goose。不能与 PreserveDirectories 同时使用
+	YearDirectories bool
+
+	// GooseNumbering 为 goose 文件名的编号方式，默认为 GooseNumberingTimestamp(由 Flyway 版本号编码的时间戳)，
+	// GooseNumberingSequential 时按版本顺序重新编号为 00001、00002 ...，对应关系记录在 ConversionReport.Numbering 中
+	GooseNumbering GooseNumbering
+
+	// NumberingStride 为 GooseNumberingStride 时相邻两个迁移的版本之差，为 0 时使用 DefaultNumberingStride
+	NumberingStride int64
+
+	// Logger 输出转换过程中的调试信息、进度和警告，为 nil 时使用 SetLogger 设置的包级别 Logger，
+	// 默认不输出任何内容
+	Logger Logger
+
+	// TemplateExtension 不为空时，将以 ".sql"+TemplateExtension 结尾的文件(如 V1__init.sql.j2)
+	// 当作迁移脚本的模板，由 TemplateRenderer 渲染后再转换，生成的文件名不含该扩展名
+	TemplateExtension string
+
+	// Extensions 为迁移脚本的扩展名(如 ".ddl"、".psql")，为空时只转换 ".sql" 文件。
+	// 生成的 goose 文件的扩展名总是 ".sql"
+	Extensions []string
+
+	// TemplateRenderer 渲染模板文件，name 为文件路径，content 为文件内容。
+	// 为 nil 时输出警告并跳过模板文件
+	TemplateRenderer func(name, content string) (string, error)
+
+	// VersionIDColumnBits 为 goose 版本表 version_id 列的整数位数(32 或 64)，为 0 时按 64 位处理。
+	// 为 32 时(version_id 为 INTEGER)检查生成的每个版本号都不超过 2147483647，
+	// 14 位的时间戳版本号一定会超出，需要把该列改为 BIGINT 或使用 GooseNumberingSequential
+	VersionIDColumnBits int
+
+	// FileValidators 在写出每个文件前依次调用，name 为 Flyway 文件路径，content 为转换后的内容，
+	// 任何一个返回错误时中止转换(ContinueOnError 时跳过该文件)，用于实现"迁移中不允许 TRUNCATE"这样的规则
+	FileValidators []func(name, content string) error
+
+	// ValidateOutput 为 true 时在写出每个文件前用 ValidateGooseSQL 检查 goose 能否解析转换后的内容，
+	// 不能解析时不写出该文件并返回错误
+	ValidateOutput bool
+
+	// ContinueOnError 为 true 时，被 FileValidators 拒绝的文件不写出，记录到 ConversionReport.Rejected 后继续转换其它文件
+	ContinueOnError bool
+}
+
+// GooseNumbering 表示 goose 文件名的编号方式
+type GooseNumbering int
+
+const (
+	// GooseNumberingTimestamp 使用 Flyway 版本号编码成的 14 位时间戳
+	GooseNumberingTimestamp GooseNumbering = iota
+	// GooseNumberingSequential 按 Flyway 版本的顺序使用连续的序号，相当于间隔为 1 的 GooseNumberingStride
+	GooseNumberingSequential
+	// GooseNumberingStride 按 Flyway 版本的顺序(而不是编码后的时间戳)编号为 1、2、3 ... 乘以 NumberingStride，
+	// 不论 Flyway 版本号是什么样的，goose 的执行顺序都与 Flyway 相同，间隔便于以后在中间插入迁移
+	GooseNumberingStride
+)
+
+// DefaultNumberingStride 是 GooseNumberingStride 默认的版本间隔
+const DefaultNumberingStride = 10
+
+// numberingStride 返回重新编号时相邻两个迁移的版本之差，GooseNumberingSequential 时为 1
+func (cfg *Config) numberingStride() int64 {
+	switch {
+	case cfg.GooseNumbering != GooseNumberingStride:
+		return 1
+	case cfg.NumberingStride == 0:
+		return DefaultNumberingStride
+	}
+	return cfg.NumberingStride
+}
+
+const (
+	// FilenameCollisionError 转换出相同的文件名时返回错误
+	FilenameCollisionError = "error"
+	// FilenameCollisionSuffix 转换出相同的文件名时加上数字后缀，需要重新编号
+	FilenameCollisionSuffix = "suffix"
+)
+
+// DefaultFlywayDirectives Config.FlywayDirectives 的默认值
+var DefaultFlywayDirectives = map[string]string{
+	"timeout": "-- NOTE: flyway timeout=%s is not enforced by goose",
+}
+
+// DefaultMaxStatementBytes Config.MaxStatementBytes 的默认值
+const DefaultMaxStatementBytes = 16 << 20
+
+const (
+	// StrictnessWarn 发现问题时输出警告并继续
+	StrictnessWarn = "warn"
+	// StrictnessError 发现问题时返回错误
+	StrictnessError = "error"
+)
+
+const (
+	// VersionStrategyAuto 版本号是 14 位数字时按时间戳处理，否则按语义化版本处理
+	VersionStrategyAuto = "auto"
+	// VersionStrategySemantic 按 Config.VersionScheme 将 x.y.z 编码为 goose 版本号(默认为 baseYear + %02d%02d%06d)
+	VersionStrategySemantic = "semantic"
+	// VersionStrategyTimestamp 版本号必须是 14 位数字(如 20230115103000)，原样作为 Goose 版本号
+	VersionStrategyTimestamp = "timestamp"
+)
+
+// migrationPrefix 返回版本化迁移脚本的文件名前缀
+func (cfg *Config) migrationPrefix() string {
+	if cfg.Prefix == "" {
+		return "V"
+	}
+	return cfg.Prefix
+}
+
+// DefaultExtensions Config.Extensions 的默认值
+var DefaultExtensions = []string{".sql"}
+
+// extensions 返回迁移脚本的扩展名
+func (cfg *Config) extensions() []string {
+	if len(cfg.Extensions) == 0 {
+		return DefaultExtensions
+	}
+	return cfg.Extensions
+}
+
+// templateScriptName 去掉模板文件名末尾的 TemplateExtension，返回渲染后的脚本名，
+// path 不是模板文件时原样返回 path 和 false
+func (cfg *Config) templateScriptName(path string) (string, bool) {
+	if cfg.TemplateExtension == "" {
+		return path, false
+	}
+	for _, ext := range cfg.extensions() {
+		if strings.HasSuffix(path, ext+cfg.TemplateExtension) {
+			return strings.TrimSuffix(path, cfg.TemplateExtension), true
+		}
+	}
+	return path, false
+}
+
+// trimScriptExtension 去掉文件名末尾的扩展名，扩展名不在 extensions 中时返回 false
+func trimScriptExtension(name string, extensions []string) (string, bool) {
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+	return name, false
+}
+
+// DefaultSeparator Config.Separator 的默认值
+const DefaultSeparator = "__"
+
+// migrationSeparator 返回文件名中版本号和描述之间的分隔符
+func (cfg *Config) migrationSeparator() string {
+	if cfg.Separator == "" {
+		return DefaultSeparator
+	}
+	return cfg.Separator
+}
+
+// maxStatementBytes 返回单条语句的最大字节数
+func (cfg *Config) maxStatementBytes() int {
+	if cfg.MaxStatementBytes <= 0 {
+		return DefaultMaxStatementBytes
+	}
+	return cfg.MaxStatementBytes
 }
 
+// NameOrder 表示 Flyway 文件名中版本号和描述的先后顺序
+type NameOrder int
+
+const (
+	// VersionFirst 表示 V1.2__desc.sql 形式的文件名
+	VersionFirst NameOrder = iota
+	// DescriptionFirst 表示 desc__V1.2.sql 形式的文件名
+	DescriptionFirst
+)
+
+// KeywordCase 表示转换时 SQL 关键字的大小写
+type KeywordCase int
+
+const (
+	// KeywordCasePreserve 保持关键字原样
+	KeywordCasePreserve KeywordCase = iota
+	// KeywordCaseLower 将关键字转为小写
+	KeywordCaseLower
+	// KeywordCaseUpper 将关键字转为大写
+	KeywordCaseUpper
+)
+
 func Convert(inputPath, outputDir, baseYear string) (string, error) {
+	_, err := ConvertWithReport(inputPath, outputDir, baseYear)
+	return outputDir, err
+}
+
+// ConvertWithReport 与 Convert 相同，并返回每个转换后文件的信息
+func ConvertWithReport(inputPath, outputDir, baseYear string) (*ConversionReport, error) {
+	return convertWithReport(&Config{
+		InputPath: inputPath,
+		OutputDir: outputDir,
+		BaseYear:  baseYear,
+	})
+}
+
+// ConvertWithConfig 按 cfg 中的选项将 cfg.InputPath 中的迁移脚本转换到 cfg.OutputDir
+func ConvertWithConfig(cfg *Config) (string, error) {
+	_, err := convertWithReport(cfg)
+	return cfg.OutputDir, err
+}
+
+// convertWithReport 与 ConvertWithConfig 相同，并返回转换了哪些文件、跳过了哪些文件
+func convertWithReport(cfg *Config) (*ConversionReport, error) {
+	inputFS, closer, err := getInputFS(nil, cfg.InputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize input filesystem: %w", err)
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	return convertFSWithReport(inputFS, cfg)
+}
+
+// ConvertFS 将 fsys 中的 Flyway 迁移脚本转换到 outputDir，fsys 可以是 go:embed 得到的 embed.FS
+func ConvertFS(fsys fs.FS, outputDir, baseYear string) error {
+	_, err := convertFSWithReport(fsys, &Config{
+		OutputDir: outputDir,
+		BaseYear:  baseYear,
+	})
+	return err
+}
+
+// ConvertChan 转换 fsys 中的 Flyway 迁移脚本，将转换出的文件按 Flyway 版本的顺序(V2 在 V10 之前)
+// 发送到返回的第一个 channel，不写出任何文件。所有文件都转换完成后才开始发送，
+// 转换失败时不发送任何文件，将第一个错误发送到第二个 channel，之后关闭两个 channel；
+// 调用者需要读完第一个 channel(或读到错误)，否则转换使用的 goroutine 不会退出
+func ConvertChan(fsys fs.FS, baseYear string) (<-chan ConvertedFile, <-chan error) {
+	files := make(chan ConvertedFile)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(files)
+		defer close(errc)
+
+		p := newFSProcessor("", &Config{BaseYear: baseYear})
+		p.ordered = true
+		p.sink = func(file ConvertedFile) {
+			files <- file
+		}
+		if _, err := p.run(fsys); err != nil {
+			errc <- err
+		}
+	}()
+	return files, errc
+}
+
+// ConvertToFS 在内存中转换 inputPath(目录或 JAR/ZIP/WAR 文件)中的 Flyway 迁移脚本，
+// 返回包含生成的 goose 文件的 fs.FS，可以直接交给 goose.SetBaseFS 执行，不写出任何文件
+func ConvertToFS(inputPath, baseYear string) (fs.FS, error) {
 	inputFS, closer, err := getInputFS(nil, inputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to initialize input filesystem: %w", err)
+		return nil, fmt.Errorf("failed to initialize input filesystem: %w", err)
 	}
 	if closer != nil {
 		defer closer.Close()
 	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create output directory: %w", err)
+	files := memFS{}
+	p := newFSProcessor("", &Config{BaseYear: baseYear})
+	p.sink = func(file ConvertedFile) {
+		files[file.Target] = []byte(file.Content)
 	}
+	if _, err := p.run(inputFS); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
 
-	err = processFS(inputFS, outputDir, baseYear)
-	return outputDir, err
+// convertFSWithReport 按 cfg 中的选项将 fsys 中的迁移脚本转换到 cfg.OutputDir，cfg.InputPath 被忽略
+func convertFSWithReport(fsys fs.FS, cfg *Config) (*ConversionReport, error) {
+	if !cfg.DryRun {
+		if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	return processFSWithReport(fsys, cfg.OutputDir, cfg)
 }
 
-func migrateWithGoose(migrationsDir, driver, connString string) error {
-	db, err := goose.OpenDBWithDriver(driver, connString)
-	if err != nil {
-		return fmt.Errorf("failed to open DB: %w", err)
+// migrateOutputDir 执行 cfg.OutputDir 中的迁移，db 为 nil 时按 cfg.DBConnString 打开数据库
+func migrateOutputDir(ctx context.Context, cfg *Config, db *sql.DB) (int64, error) {
+	if db == nil {
+		opened, err := goose.OpenDBWithDriver(cfg.DBDriver, cfg.DBConnString)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open DB: %w", err)
+		}
+		defer opened.Close()
+		db = opened
+	}
+
+	if cfg.SingleTransaction {
+		return migrateInSingleTransaction(ctx, db, cfg.OutputDir, cfg.DBDriver)
+	}
+	return migrateWithGoose(ctx, db, cfg.OutputDir, cfg.DBDriver, cfg.logger())
+}
+
+// migrateWithGoose 用 goose 执行 migrationsDir 中的迁移，返回执行后的数据库版本
+func migrateWithGoose(ctx context.Context, db *sql.DB, migrationsDir, driver string, logger Logger) (int64, error) {
+	if _, err := CheckDialect(db, driver); err != nil {
+		logger.Warnf("%v", err)
 	}
-	defer db.Close()
 
-	if err := goose.SetDialect(driver); err != nil {
-		return fmt.Errorf("failed to set dialect: %w", err)
+	if err := goose.SetDialect(gooseDialect(driver)); err != nil {
+		return 0, fmt.Errorf("failed to set dialect: %w", err)
 	}
 
-	return goose.Up(db, migrationsDir)
+	if err := goose.UpContext(ctx, db, migrationsDir); err != nil {
+		return 0, err
+	}
+	return goose.GetDBVersionContext(ctx, db)
 }
 
 func ConvertAndMigrate(cfg *Config) error {
-	var migrationsDir string
-	var err error
+	return ConvertAndMigrateContext(context.Background(), cfg)
+}
+
+// ConvertAndMigrateContext 与 ConvertAndMigrate 相同，执行迁移时使用 ctx，可以取消或设置超时
+func ConvertAndMigrateContext(ctx context.Context, cfg *Config) error {
+	return convertAndMigrate(ctx, cfg, nil)
+}
+
+// ConvertAndMigrateDB 与 ConvertAndMigrate 相同，但使用调用者提供的 db 执行迁移，
+// 不会按 cfg.DBConnString 打开数据库，也不会关闭 db，cfg.DBDriver 仍用于确定方言
+func ConvertAndMigrateDB(cfg *Config, db *sql.DB) error {
+	return convertAndMigrate(context.Background(), cfg, db)
+}
+
+// convertAndMigrate 转换并执行迁移，db 为 nil 时按 cfg.DBConnString 打开数据库并在结束时关闭
+func convertAndMigrate(ctx context.Context, cfg *Config, db *sql.DB) (err error) {
+	var audit *RunAudit
+	if cfg.AuditFile != "" {
+		audit = newRunAudit("run", cfg)
+		defer func() {
+			if auditErr := audit.finish(cfg.AuditFile, err); auditErr != nil && err == nil {
+				err = auditErr
+			}
+		}()
+	}
+
+	if cfg.SingleTransaction {
+		if _, ok := transactionalDialects[DriverFamily(cfg.DBDriver)]; !ok {
+			return fmt.Errorf("single transaction is not supported for driver %q: DDL statements cannot be rolled back", cfg.DBDriver)
+		}
+	}
 
 	useTempDir := false
 	if cfg.OutputDir == "" {
@@ -68,12 +599,18 @@ func ConvertAndMigrate(cfg *Config) error {
 		useTempDir = true
 	}
 
-	migrationsDir, err = Convert(cfg.InputPath, cfg.OutputDir, cfg.BaseYear)
+	conversion, err := convertWithReport(cfg)
 	if err != nil {
 		return err
 	}
 
-	err = migrateWithGoose(migrationsDir, cfg.DBDriver, cfg.DBConnString)
+	version, err := migrateOutputDir(ctx, cfg, db)
+	if audit != nil {
+		audit.Conversion = conversion
+		if err == nil {
+			audit.Migration = &MigrationResult{FinalVersion: version}
+		}
+	}
 
 	if useTempDir {
 		// 如果使用了临时目录，迁移完成后删除
@@ -84,6 +621,8 @@ func ConvertAndMigrate(cfg *Config) error {
 }
 
 func RunMain() {
+	SetLogger(NewStdLogger(nil))
+
 	command, cfg, err := parseArgs()
 	if err != nil {
 		fmt.Printf("参数错误: %v\n", err)
@@ -94,12 +633,33 @@ func RunMain() {
 	var executeErr error
 	switch command {
 	case "convert":
-		if cfg.InputPath == "" || cfg.OutputDir == "" {
+		if cfg.InputPath == "" || (cfg.OutputDir == "" && !cfg.DryRun) {
 			fmt.Println("convert 命令需要 input 和 output 参数")
 			flag.Usage()
 			os.Exit(1)
 		}
-		_, executeErr = Convert(cfg.InputPath, cfg.OutputDir, cfg.BaseYear)
+		_, executeErr = ConvertWithConfig(cfg)
+	case "verify":
+		if cfg.InputPath == "" {
+			fmt.Println("verify 命令需要 input 参数")
+			flag.Usage()
+			os.Exit(1)
+		}
+		var mismatches []VerifyMismatch
+		mismatches, executeErr = Verify(cfg)
+		for _, mismatch := range mismatches {
+			fmt.Println(mismatch)
+		}
+		if executeErr == nil && len(mismatches) > 0 {
+			executeErr = fmt.Errorf("%d files failed verification", len(mismatches))
+		}
+	case "baseline":
+		if cfg.OutputDir == "" || cfg.DBDriver == "" || cfg.DBConnString == "" {
+			fmt.Println("baseline 命令需要 output，db_driver 和 db_url 参数")
+			flag.Usage()
+			os.Exit(1)
+		}
+		executeErr = runBaseline(cfg)
 	case "run":
 		if cfg.InputPath == "" || cfg.DBDriver == "" || cfg.DBConnString == "" {
 			fmt.Println("run 命令需要 input，db_driver 和 db_url 参数")
@@ -130,21 +690,94 @@ func parseArgs() (string, *Config, error) {
 	switch command {
 	case "convert":
 		convertCmd := flag.NewFlagSet("convert", flag.ExitOnError)
-		convertCmd.StringVar(&cfg.InputPath, "input", "", "输入路径(JAR文件或目录)(必需)")
+		convertCmd.StringVar(&cfg.InputPath, "input", "", "输入路径(JAR/ZIP/WAR文件或目录)(必需)")
 		convertCmd.StringVar(&cfg.OutputDir, "output", "", "输出目录(必需)")
 		convertCmd.StringVar(&cfg.BaseYear, "year", "2000", "基础年份(用于版本转换)")
-		if err := convertCmd.Parse(os.Args[2:]); err != nil {
+		convertCmd.StringVar(&cfg.Prefix, "prefix", "V", "迁移脚本的文件名前缀")
+		convertCmd.StringVar(&cfg.Separator, "separator", DefaultSeparator, "文件名中版本号和描述之间的分隔符")
+		convertCmd.BoolVar(&cfg.GenerateDown, "gen_down", false, "为可以反转的迁移生成 Down 语句")
+		convertCmd.StringVar(&cfg.DownStub, "down_stub", "", "没有 Down 语句时 Down 部分的内容(如 SELECT 'no-op';)")
+		convertCmd.BoolVar(&cfg.SplitStatements, "split", false, "将每条语句转换为单独的 goose 迁移")
+		convertCmd.IntVar(&cfg.StatementStats, "stats", 0, "转换后输出字节数最大的 N 条语句")
+		convertCmd.StringVar(&cfg.RollbackDir, "rollback_dir", "", "回滚脚本所在的目录，按版本号作为对应迁移的 Down 部分")
+		convertCmd.BoolVar(&cfg.DryRun, "dry_run", false, "只打印转换后的文件名，不写出文件")
+		convertCmd.BoolVar(&cfg.ValidateOutput, "validate", false, "写出前检查 goose 能否解析转换后的文件")
+		convertCmd.BoolVar(&cfg.ShowDown, "show_down", false, "打印每个文件推断出的 Down 语句")
+		convertCmd.StringVar(&cfg.EmbedPackage, "embed_package", "", "生成使用 go:embed 打包迁移文件的 Go 文件，值为包名")
+		locations := convertCmd.String("locations", "", "只转换输入中的这些子目录，多个目录用逗号分隔")
+		extensions := convertCmd.String("extensions", "", "迁移脚本的扩展名，多个扩展名用逗号分隔(默认.sql)")
+		dialects := convertCmd.String("dialects", "", "检查迁移是否兼容这些数据库(mysql、postgres、sqlite3)，多个用逗号分隔")
+		convertCmd.BoolVar(&cfg.PreserveDirectories, "preserve_dirs", false, "在输出目录中保留迁移脚本的相对目录")
+		convertCmd.BoolVar(&cfg.YearDirectories, "year_dirs", false, "按 goose 版本的年份把生成的文件放到子目录中")
+		sequential := convertCmd.Bool("sequential", false, "按版本顺序使用 00001、00002 ... 作为 goose 版本")
+		convertCmd.Int64Var(&cfg.NumberingStride, "stride", 0, "按 Flyway 版本的顺序使用 1、2、3 ... 乘以该值作为 goose 版本")
+		if err := parseFlags(convertCmd, os.Args[2:]); err != nil {
+			return command, nil, err
+		}
+		if *locations != "" {
+			cfg.Locations = strings.Split(*locations, ",")
+		}
+		if *extensions != "" {
+			cfg.Extensions = strings.Split(*extensions, ",")
+		}
+		if *dialects != "" {
+			cfg.Dialects = strings.Split(*dialects, ",")
+		}
+		if *sequential && cfg.NumberingStride > 0 {
+			return command, nil, fmt.Errorf("-sequential and -stride cannot be used together")
+		}
+		if *sequential {
+			cfg.GooseNumbering = GooseNumberingSequential
+		}
+		if cfg.NumberingStride > 0 {
+			cfg.GooseNumbering = GooseNumberingStride
+		}
+
+	case "verify":
+		verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+		verifyCmd.StringVar(&cfg.InputPath, "input", "", "输入路径(JAR/ZIP/WAR文件或目录)(必需)")
+		verifyCmd.StringVar(&cfg.BaseYear, "year", "2000", "基础年份(用于版本转换)")
+		verifyCmd.StringVar(&cfg.Prefix, "prefix", "V", "迁移脚本的文件名前缀")
+		verifyCmd.StringVar(&cfg.Separator, "separator", DefaultSeparator, "文件名中版本号和描述之间的分隔符")
+		locations := verifyCmd.String("locations", "", "只检查输入中的这些子目录，多个目录用逗号分隔")
+		extensions := verifyCmd.String("extensions", "", "迁移脚本的扩展名，多个扩展名用逗号分隔(默认.sql)")
+		if err := parseFlags(verifyCmd, os.Args[2:]); err != nil {
+			return command, nil, err
+		}
+		if *locations != "" {
+			cfg.Locations = strings.Split(*locations, ",")
+		}
+		if *extensions != "" {
+			cfg.Extensions = strings.Split(*extensions, ",")
+		}
+
+	case "baseline":
+		baselineCmd := flag.NewFlagSet("baseline", flag.ExitOnError)
+		baselineCmd.StringVar(&cfg.OutputDir, "output", "", "输出目录(必需)")
+		baselineCmd.StringVar(&cfg.SchemaFile, "schema_file", "", "数据库结构文件(如 pg_dump --schema-only 的输出)，为空时对 Postgres 执行 pg_dump")
+		baselineCmd.StringVar(&cfg.BaseYear, "year", "2000", "基础年份(用于版本转换)")
+		baselineCmd.StringVar(&cfg.DBDriver, "db_driver", "postgres", "数据库驱动(postgres/mysql/sqlite3等)")
+		baselineCmd.StringVar(&cfg.DBConnString, "db_url", "", "数据库连接字符串(必需)")
+		if err := parseFlags(baselineCmd, os.Args[2:]); err != nil {
 			return command, nil, err
 		}
 
 	case "run":
 		runCmd := flag.NewFlagSet("run", flag.ExitOnError)
-		runCmd.StringVar(&cfg.InputPath, "input", "", "输入路径(JAR文件或目录)(必需)")
+		runCmd.StringVar(&cfg.InputPath, "input", "", "输入路径(JAR/ZIP/WAR文件或目录)(必需)")
 		runCmd.StringVar(&cfg.OutputDir, "output", "", "输出目录(可选，为空时使用临时目录)")
 		runCmd.StringVar(&cfg.BaseYear, "year", "2000", "基础年份(用于版本转换)")
+		runCmd.StringVar(&cfg.Prefix, "prefix", "V", "迁移脚本的文件名前缀")
+		runCmd.StringVar(&cfg.Separator, "separator", DefaultSeparator, "文件名中版本号和描述之间的分隔符")
+		runCmd.BoolVar(&cfg.GenerateDown, "gen_down", false, "为可以反转的迁移生成 Down 语句")
+		runCmd.StringVar(&cfg.DownStub, "down_stub", "", "没有 Down 语句时 Down 部分的内容(如 SELECT 'no-op';)")
+		runCmd.BoolVar(&cfg.SplitStatements, "split", false, "将每条语句转换为单独的 goose 迁移")
 		runCmd.StringVar(&cfg.DBDriver, "db_driver", "postgres", "数据库驱动(postgres/mysql/sqlite3等)")
 		runCmd.StringVar(&cfg.DBConnString, "db_url", "", "数据库连接字符串(必需)")
-		if err := runCmd.Parse(os.Args[2:]); err != nil {
+		runCmd.StringVar(&cfg.TargetSchema, "target_schema", "", "在每个迁移的 Up 部分开头切换到该 schema(Postgres 为 search_path，MySQL 为数据库)")
+		runCmd.StringVar(&cfg.RestoreSchema, "restore_schema", "", "使用 -target_schema 时 Up 部分结尾切换回的 schema(goose 版本表所在的 schema，MySQL 必需)")
+		runCmd.StringVar(&cfg.AuditFile, "audit_file", "", "将本次执行的审计记录以 JSON 写入该文件(如 run.json)")
+		if err := parseFlags(runCmd, os.Args[2:]); err != nil {
 			return command, nil, err
 		}
 	default:
@@ -155,61 +788,200 @@ func parseArgs() (string, *Config, error) {
 	return command, cfg, nil
 }
 
+// EnvPrefix 是命令行参数对应的环境变量名的前缀，如 -db_url 对应 FLYWAY2GOOSE_DB_URL
+const EnvPrefix = "FLYWAY2GOOSE_"
+
+// flagEnvNames 为环境变量名与参数名不同的参数，参数名 -> 去掉 EnvPrefix 的环境变量名
+var flagEnvNames = map[string]string{
+	"year": "BASE_YEAR",
+}
+
+// parseFlags 解析 args，命令行中没有指定的参数使用对应的环境变量(见 EnvPrefix)的值，
+// 优先级为 命令行参数 > 环境变量 > 默认值，以便在容器中运行时不必把连接串等放在命令行中
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		name := flagEnvNames[f.Name]
+		if name == "" {
+			name = strings.ToUpper(f.Name)
+		}
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			if e := fs.Set(f.Name, value); e != nil {
+				err = fmt.Errorf("invalid value %q for environment variable %s: %w", value, EnvPrefix+name, e)
+			}
+		}
+	})
+	return err
+}
+
 func printUsage() {
 	fmt.Println("使用方法:")
+	fmt.Println("  没有指定的参数使用 FLYWAY2GOOSE_ 加上大写参数名的环境变量(如 FLYWAY2GOOSE_DB_URL，-year 为 FLYWAY2GOOSE_BASE_YEAR)")
 	fmt.Println("  convert - 仅转换迁移脚本")
-	fmt.Println("    flyway convert -input <path> -output <dir> [-year <year>]")
+	fmt.Println("    flyway convert -input <path> -output <dir> [-year <year>] [-prefix <prefix>] [-separator <sep>] [-gen_down] [-down_stub <text>] [-split] [-stats <n>] [-rollback_dir <dir>] [-show_down] [-dry_run] [-validate] [-embed_package <name>] [-locations <dir,...>] [-extensions <ext,...>] [-dialects <db,...>] [-preserve_dirs] [-year_dirs] [-sequential] [-stride <n>]")
 	fmt.Println("    参数:")
 	fmt.Println("      -year:   可选，基础年份(默认2000)")
-	fmt.Println("      -input:  必需，输入路径(JAR文件或目录)")
+	fmt.Println("      -input:  必需，输入路径(JAR/ZIP/WAR文件或目录)")
 	fmt.Println("      -output: 必需，输出目录")
+	fmt.Println("      -prefix: 可选，迁移脚本的文件名前缀(默认V)")
+	fmt.Println("      -separator: 可选，文件名中版本号和描述之间的分隔符(默认__)")
+	fmt.Println("      -gen_down: 可选，为可以反转的迁移生成 Down 语句")
+	fmt.Println("      -down_stub: 可选，没有 Down 语句时 Down 部分的内容(如 SELECT 'no-op';)，默认为一行注释")
+	fmt.Println("      -split: 可选，将每条语句转换为单独的 goose 迁移")
+	fmt.Println("      -stats: 可选，转换后输出字节数最大的 N 条语句")
+	fmt.Println("      -rollback_dir: 可选，回滚脚本所在的目录，按版本号作为对应迁移的 Down 部分")
+	fmt.Println("      -show_down: 可选，打印推断出的 Down 语句")
+	fmt.Println("      -dry_run: 可选，只打印转换后的文件名并检查重名，不写出文件")
+	fmt.Println("      -validate: 可选，写出前检查 goose 能否解析转换后的文件，不能解析时报错")
+	fmt.Println("      -embed_package: 可选，在输出目录生成 migrations_embed.go 并使用该包名")
+	fmt.Println("      -locations: 可选，只转换输入中的这些子目录，多个目录用逗号分隔")
+	fmt.Println("      -extensions: 可选，迁移脚本的扩展名(如 .sql,.ddl)，多个扩展名用逗号分隔，默认为 .sql")
+	fmt.Println("      -dialects: 可选，检查迁移是否使用了这些数据库(如 mysql,postgres)不支持的语法，只输出警告")
+	fmt.Println("      -preserve_dirs: 可选，在输出目录中保留迁移脚本的相对目录")
+	fmt.Println("      -year_dirs: 可选，按 goose 版本的年份把生成的文件放到子目录中，应与 -embed_package 一起使用")
+	fmt.Println("      -sequential: 可选，按 Flyway 版本的顺序使用 00001、00002 ... 作为 goose 版本，不能与 -stride 同时使用")
+	fmt.Println("      -stride: 可选，按 Flyway 版本的顺序使用 1、2、3 ... 乘以该值(如 10)作为 goose 版本，保证执行顺序与 Flyway 相同")
 
 	fmt.Println("\n  run - 转换并执行迁移")
-	fmt.Println("    flyway run -input <path> [-db_driver <name>] -db_url <conn> [-output <dir>] [-year <year>]")
+	fmt.Println("    flyway run -input <path> [-db_driver <name>] -db_url <conn> [-output <dir>] [-year <year>] [-prefix <prefix>] [-separator <sep>] [-gen_down] [-down_stub <text>] [-split] [-target_schema <schema>] [-restore_schema <schema>] [-audit_file <path>]")
 	fmt.Println("    参数:")
 	fmt.Println("      -year:   可选，基础年份(默认2000)")
-	fmt.Println("      -input:  必需，输入路径(JAR文件或目录)")
+	fmt.Println("      -input:  必需，输入路径(JAR/ZIP/WAR文件或目录)")
 	fmt.Println("      -output: 可选，输出目录(为空时使用临时目录)")
+	fmt.Println("      -prefix: 可选，迁移脚本的文件名前缀(默认V)")
+	fmt.Println("      -separator: 可选，文件名中版本号和描述之间的分隔符(默认__)")
+	fmt.Println("      -gen_down: 可选，为可以反转的迁移生成 Down 语句")
+	fmt.Println("      -down_stub: 可选，没有 Down 语句时 Down 部分的内容(如 SELECT 'no-op';)，默认为一行注释")
+	fmt.Println("      -split: 可选，将每条语句转换为单独的 goose 迁移")
 	fmt.Println("      -db_driver:  可选，数据库驱动(默认postgres)")
 	fmt.Println("      -db_url:     必需，数据库连接字符串")
+	fmt.Println("      -target_schema: 可选，在每个迁移的 Up 部分开头切换到该 schema")
+	fmt.Println("      -restore_schema: 可选，使用 -target_schema 时 Up 部分结尾切换回的 schema(goose 版本表所在的 schema，MySQL 必需)")
+	fmt.Println("      -audit_file: 可选，将审计记录以 JSON 写入该文件(连接串中的密码会被隐去)")
+
+	fmt.Println("\n  baseline - 用数据库当前的结构生成一个基线迁移，并将 Flyway 表中的版本标记为已执行")
+	fmt.Println("    flyway baseline -output <dir> [-db_driver <name>] -db_url <conn> [-schema_file <path>] [-year <year>]")
+	fmt.Println("    参数:")
+	fmt.Println("      -output: 必需，输出目录")
+	fmt.Println("      -db_driver:  可选，数据库驱动(默认postgres)")
+	fmt.Println("      -db_url:     必需，数据库连接字符串")
+	fmt.Println("      -schema_file: 可选，数据库结构文件(如 pg_dump --schema-only 的输出)，为空时对 Postgres 执行 pg_dump")
+	fmt.Println("      -year:   可选，基础年份(默认2000)")
+
+	fmt.Println("\n  verify - 检查 goose 解析转换后的文件得到的语句数与原文件相同，不一致时以非 0 退出")
+	fmt.Println("    flyway verify -input <path> [-year <year>] [-prefix <prefix>] [-separator <sep>] [-locations <dir,...>] [-extensions <ext,...>]")
+	fmt.Println("    参数:")
+	fmt.Println("      -input:  必需，输入路径(JAR/ZIP/WAR文件或目录)")
+	fmt.Println("      -year:   可选，基础年份(默认2000)")
+	fmt.Println("      -prefix: 可选，迁移脚本的文件名前缀(默认V)")
+	fmt.Println("      -separator: 可选，文件名中版本号和描述之间的分隔符(默认__)")
+	fmt.Println("      -locations: 可选，只检查输入中的这些子目录，多个目录用逗号分隔")
+	fmt.Println("      -extensions: 可选，迁移脚本的扩展名，多个扩展名用逗号分隔，默认为 .sql")
+}
+
+// archiveMigrationDirs 支持的压缩包扩展名 -> 压缩包中存放迁移脚本的目录，它们都是 ZIP 格式
+var archiveMigrationDirs = map[string]string{
+	".jar": "db/migration",
+	".war": "WEB-INF/classes/db/migration",
+	".zip": "db/migration",
+}
+
+// zipMagic 是 ZIP 文件开头的标识
+var zipMagic = []byte("PK\x03\x04")
+
+// isArchiveName 判断文件名是否为支持的压缩包(.jar/.zip/.war)
+func isArchiveName(name string) bool {
+	_, ok := archiveMigrationDirs[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// hasZipMagic 判断文件是否以 ZIP 标识开头
+func hasZipMagic(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, zipMagic)
 }
 
-// getInputFS 根据输入路径返回适当的文件系统实现
+// getInputFS 根据输入路径返回适当的文件系统实现，目录直接使用，
+// .jar/.zip/.war 或以 ZIP 标识开头的文件按压缩包打开，其它普通文件返回错误
 func getInputFS(fsys fs.FS, inputPath string) (fs.FS, io.Closer, error) {
-	if strings.HasSuffix(strings.ToLower(inputPath), ".jar") {
+	isArchive := isArchiveName(inputPath)
+	if !isArchive && fsys == nil {
+		fi, err := os.Stat(inputPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open input path: %w", err)
+		}
+		if !fi.IsDir() {
+			if !hasZipMagic(inputPath) {
+				return nil, nil, fmt.Errorf("%s is neither a directory nor a .jar/.zip/.war archive", inputPath)
+			}
+			isArchive = true
+		}
+	}
 
+	if isArchive {
 		var filefs fs.FS
 		var closer io.Closer
 
 		if fsys != nil {
 			f, err := fsys.Open(inputPath)
 			if err != nil {
-				return nil, nil, fmt.Errorf("failed to open JAR file: %w", err)
+				return nil, nil, fmt.Errorf("failed to open archive %s: %w", inputPath, err)
 			}
 			fi, err := f.Stat()
 			if err != nil {
 				f.Close()
-				return nil, nil, fmt.Errorf("failed to read JAR file size: %w", err)
+				return nil, nil, fmt.Errorf("failed to read archive size %s: %w", inputPath, err)
 			}
 
-			zipFS, err := zip.NewReader(f.(io.ReaderAt), fi.Size())
+			readerAt, ok := f.(io.ReaderAt)
+			if !ok {
+				f.Close()
+				return nil, nil, fmt.Errorf("failed to open archive %s: file does not support random access", inputPath)
+			}
+			zipFS, err := zip.NewReader(readerAt, fi.Size())
 			if err != nil {
-				return nil, nil, fmt.Errorf("failed to open JAR file: %w", err)
+				f.Close()
+				return nil, nil, fmt.Errorf("failed to open archive %s: %w", inputPath, err)
 			}
 			filefs = zipFS
 			closer = f
 		} else {
 			zipFS, err := zip.OpenReader(inputPath)
 			if err != nil {
-				return nil, nil, fmt.Errorf("failed to open JAR file: %w", err)
+				return nil, nil, fmt.Errorf("failed to open archive %s: %w", inputPath, err)
 			}
 			filefs = zipFS
 			closer = zipFS
 		}
-		subFs, err := fs.Sub(filefs, "db/migration")
+
+		dir, ok := archiveMigrationDirs[strings.ToLower(filepath.Ext(inputPath))]
+		if !ok {
+			dir = archiveMigrationDirs[".zip"]
+		}
+		subFs, err := fs.Sub(filefs, dir)
 		if err != nil {
 			closer.Close()
-			return nil, nil, fmt.Errorf("failed to change sub dir(db/migration): %w", err)
+			return nil, nil, fmt.Errorf("failed to change sub dir(%s): %w", dir, err)
 		}
 		return subFs, closer, nil
 	}
@@ -220,88 +992,842 @@ func getInputFS(fsys fs.FS, inputPath string) (fs.FS, io.Closer, error) {
 	return dir, nil, err
 }
 
-// processFS 处理文件系统中的 Flyway 迁移文件
-func processFS(fsys fs.FS, outputDir string, baseYear string) error {
-	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
-		if path == "." {
-			return nil
-		}
+// ConversionReport 记录一次转换写出和跳过的文件
+type ConversionReport struct {
+	Written []ConvertedFile `json:"written"`
+	Skipped []string        `json:"skipped"` // 不是 Flyway 迁移脚本或没有对应 V 文件的 U 文件
+	Resumed []string        `json:"resumed"` // Resume 模式下已是最新而没有重新写出的 goose 文件名
 
-		if path == ".." {
-			return nil
-		}
+	// Numbering GooseNumberingSequential 或 GooseNumberingStride 时 Flyway 文件路径 -> 重新编号后的 goose 版本，
+	// 拆分语句时一个文件对应多个版本，按执行顺序排列
+	Numbering map[string][]int64 `json:"numbering,omitempty"`
 
-		if err != nil {
-			return err
-		}
+	// FlywayVersions GooseNumberingSequential 或 GooseNumberingStride 时 Flyway 版本号 -> 重新编号后的 goose 版本，
+	// 拆分语句时为最后一条语句的版本，复制版本表时通过 CopyOptions.Numbering 使用
+	FlywayVersions map[string]int64 `json:"flyway_versions,omitempty"`
 
-		if d.IsDir() {
-			return nil
-		}
+	// Rejected ContinueOnError 时被 FileValidators 拒绝而没有写出的文件
+	Rejected []RejectedFile `json:"rejected,omitempty"`
 
-		if !isFlywayFilename(path) {
-			if ext := filepath.Ext(path); strings.ToLower(ext) == ".jar" {
-				subfs, closer, err := getInputFS(fsys, path)
-				if err != nil {
-					return err
-				}
-				defer closer.Close()
+	// DialectIssues 设置了 Config.Dialects 时发现的不兼容的语法
+	DialectIssues []DialectIssue `json:"dialect_issues,omitempty"`
+}
 
-				return processFS(subfs, outputDir, baseYear)
-			}
-			return nil
-		}
+// RejectedFile 表示一个被 FileValidators 拒绝的文件
+type RejectedFile struct {
+	Source string `json:"source"` // Flyway 文件路径
+	Reason string `json:"reason"` // 拒绝的原因
+}
 
-		file, err := fsys.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to open %s: %w", path, err)
-		}
-		defer file.Close()
+// ConvertedFile 表示一个已转换的文件
+type ConvertedFile struct {
+	Source  string `json:"source"`   // Flyway 文件路径
+	Target  string `json:"target"`   // 生成的 goose 文件名，PreserveDirectories 时为相对于输出目录的路径
+	Version int64  `json:"version"`  // goose 版本号
+	HasDown bool   `json:"has_down"` // 是否生成了 Down 部分(来自 undo 脚本、回滚脚本或 GenerateDown)
+	Content string `json:"-"`        // 转换后的内容，只有 ConvertChan 返回的文件中有值
+}
 
-		content, err := ConvertFlywayToGoose(utfbom.SkipOnly(file))
+// fsProcessor 保存一次转换过程中的状态
+type fsProcessor struct {
+	cfg       *Config
+	outputDir string
+	checksums map[string]string // goose 文件名 -> 文件内容的哈希
+	targets   map[string]string // goose 文件名 -> Flyway 文件路径
+	versions  map[int64]string  // goose 版本 -> Flyway 文件路径
+	report    *ConversionReport
+	objects   []migrationObjects // DependencyCheck 时收集的每个迁移创建和引用的对象
+	now       time.Time          // ${flyway:timestamp} 的值
+	pending   []convertedOutput  // GooseNumberingSequential 时等待重新编号后写出的文件
+
+	rollbackFS      fs.FS             // RollbackDir 对应的文件系统
+	rollbacks       map[string]string // RollbackDir 中回滚脚本的 版本号 -> 路径
+	pairedRollbacks map[string]bool   // 已经与迁移配对的回滚脚本的版本号
+
+	sink    func(ConvertedFile) // 不为 nil 时将转换后的文件交给它而不写出到 outputDir
+	ordered bool                // 为 true 时转换完所有文件后再按 Flyway 版本的顺序写出
+
+	// inspect 不为 nil 时，每个 Flyway 文件转换完成后以文件路径、原始内容和转换后的内容调用它
+	inspect func(path, text string, contents []string)
+
+	statements []sourceStatement // StatementStats 时收集的所有语句
+}
+
+// sourceStatement 是 Flyway 文件中的一条语句
+type sourceStatement struct {
+	source string
+	Statement
+}
+
+// convertedOutput 是一个转换完成、等待写出的迁移
+type convertedOutput struct {
+	source  string // Flyway 文件路径
+	target  string // goose 文件名
+	version int64  // goose 版本号
+	content string
+	hasDown bool
+
+	flywayVersion string // Flyway 版本号，重新编号时按它排序
+}
+
+// processFS 处理文件系统中的 Flyway 迁移文件
+func processFS(fsys fs.FS, outputDir string, cfg *Config) error {
+	_, err := processFSWithReport(fsys, outputDir, cfg)
+	return err
+}
+
+// processFSWithReport 与 processFS 相同，并返回转换报告
+func processFSWithReport(fsys fs.FS, outputDir string, cfg *Config) (*ConversionReport, error) {
+	return newFSProcessor(outputDir, cfg).run(fsys)
+}
+
+func newFSProcessor(outputDir string, cfg *Config) *fsProcessor {
+	return &fsProcessor{
+		cfg:       cfg,
+		outputDir: outputDir,
+		checksums: map[string]string{},
+		targets:   map[string]string{},
+		versions:  map[int64]string{},
+		report:    &ConversionReport{},
+		now:       cfg.PlaceholderTime,
+	}
+}
+
+// run 转换 fsys 中的迁移脚本并返回转换报告
+func (p *fsProcessor) run(fsys fs.FS) (*ConversionReport, error) {
+	cfg, outputDir := p.cfg, p.outputDir
+	switch cfg.FilenameCollision {
+	case FilenameCollisionSuffix:
+		// 时间戳编号时加了后缀的文件仍然使用相同的 goose 版本，goose 会拒绝执行
+		if cfg.GooseNumbering == GooseNumberingTimestamp {
+			return nil, fmt.Errorf("filename collision mode %q requires sequential or stride numbering", cfg.FilenameCollision)
+		}
+	case "", FilenameCollisionError:
+	default:
+		return nil, fmt.Errorf("unknown filename collision mode %q", cfg.FilenameCollision)
+	}
+	switch cfg.VersionIDColumnBits {
+	case 0, 32, 64:
+	default:
+		return nil, fmt.Errorf("unsupported version_id column bits %d, must be 32 or 64", cfg.VersionIDColumnBits)
+	}
+	for _, dialect := range cfg.Dialects {
+		if !portabilityDialects[DriverFamily(dialect)] {
+			return nil, fmt.Errorf("unsupported dialect %q for portability check", dialect)
+		}
+	}
+	if cfg.YearDirectories && cfg.PreserveDirectories {
+		return nil, fmt.Errorf("year directories cannot be used together with preserved directories")
+	}
+	if cfg.NumberingStride < 0 {
+		return nil, fmt.Errorf("invalid numbering stride %d", cfg.NumberingStride)
+	}
+	if p.now.IsZero() {
+		p.now = time.Now()
+	}
+	if cfg.RollbackDir != "" {
+		p.rollbackFS = os.DirFS(cfg.RollbackDir)
+		rollbacks, err := collectVersionedFiles(p.rollbackFS, ".", cfg.migrationPrefix(), cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rollback dir %s: %w", cfg.RollbackDir, err)
+		}
+		p.rollbacks = rollbacks
+		p.pairedRollbacks = map[string]bool{}
+	}
+	if len(cfg.Locations) == 0 {
+		if err := p.walk(fsys, "."); err != nil {
+			return nil, err
+		}
+	}
+	for _, location := range cfg.Locations {
+		root := filepath.ToSlash(filepath.Clean(location))
+		fi, err := fs.Stat(fsys, root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid location %s: %w", location, err)
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("invalid location %s: not a directory", location)
+		}
+		if err := p.walk(fsys, root); err != nil {
+			return nil, err
+		}
+	}
+
+	p.warnUnpairedRollbacks()
+
+	if cfg.GooseNumbering == GooseNumberingSequential || cfg.GooseNumbering == GooseNumberingStride {
+		if err := p.emitSequential(); err != nil {
+			return nil, err
+		}
+	} else if err := p.emitPending(); err != nil {
+		return nil, err
+	}
+
+	for _, warning := range checkDependencyOrder(p.objects) {
+		cfg.logger().Warnf("%s", warning)
+	}
+	p.logStatementStats()
+
+	if cfg.EmitChecksums && !cfg.DryRun {
+		if err := writeChecksumFile(outputDir, p.checksums); err != nil {
+			return nil, err
+		}
+	}
+	// go:embed 的模式没有匹配的文件时无法编译，因此没有转换任何文件时不生成
+	if cfg.EmbedPackage != "" && !cfg.DryRun && len(p.report.Written) > 0 {
+		if err := writeEmbedFile(outputDir, cfg.EmbedPackage, cfg.YearDirectories); err != nil {
+			return nil, err
+		}
+	}
+	return p.report, nil
+}
+
+// walk 转换 fsys 中 root 目录下的迁移脚本
+func (p *fsProcessor) walk(fsys fs.FS, root string) error {
+	cfg := p.cfg
+
+	undos, err := collectUndoFiles(fsys, root, cfg)
+	if err != nil {
+		return err
+	}
+	pairedUndos := map[string]bool{}
+
+	err = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if path == "." {
+			return nil
+		}
+
+		if path == ".." {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		name, templated := cfg.templateScriptName(path)
+		if !isFlywayFilename(name, cfg) {
+			if isArchiveName(path) {
+				subfs, closer, err := getInputFS(fsys, path)
+				if err != nil {
+					return err
+				}
+				defer closer.Close()
+
+				return p.walk(subfs, ".")
+			}
+			if !isFlywayScript(name, "U", cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions()) {
+				cfg.logger().Debugf("skipping %s: %s", path, flywaySkipReason(name, cfg.migrationPrefix(), cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions()))
+				p.report.Skipped = append(p.report.Skipped, path)
+			} else if templated && cfg.TemplateRenderer == nil {
+				cfg.logger().Warnf("skipping %s: no TemplateRenderer configured", path)
+				p.report.Skipped = append(p.report.Skipped, path)
+			}
+			return nil
+		}
+		if templated && cfg.TemplateRenderer == nil {
+			cfg.logger().Warnf("skipping %s: no TemplateRenderer configured", path)
+			p.report.Skipped = append(p.report.Skipped, path)
+			return nil
+		}
+
+		text, err := p.readSource(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		if err := checkMixedDDL(path, text, cfg); err != nil {
+			return err
+		}
+		if err := warnMissingTerminators(path, text, cfg); err != nil {
+			return err
+		}
+
+		// 有对应的 U 文件时，将其作为 Down 部分，否则使用 RollbackDir 中的回滚脚本
+		var undo io.Reader
+		if key, err := flywayVersionKey(name, cfg.migrationPrefix(), cfg); err == nil {
+			if undos[key] != "" {
+				undoText, err := p.readSource(fsys, undos[key])
+				if err != nil {
+					return err
+				}
+				undo = strings.NewReader(undoText)
+				pairedUndos[key] = true
+				if p.rollbacks[key] != "" {
+					cfg.logger().Warnf("%s has both undo script %s and rollback script %s, using the undo script", path, undos[key], p.rollbacks[key])
+				}
+			} else if p.rollbacks[key] != "" {
+				rollbackText, err := p.readSource(p.rollbackFS, p.rollbacks[key])
+				if err != nil {
+					return err
+				}
+				undo = strings.NewReader(rollbackText)
+				p.pairedRollbacks[key] = true
+			}
+		}
+
+		outputs, err := convertParts(text, undo, path, cfg)
 		// content, err := io.ReadAll(file)
 		if err != nil {
 			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
 
-		gooseName, err := convertToGooseFilename(path, baseYear)
+		if cfg.EmitFlywayChecksum {
+			source, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			for idx := range outputs {
+				outputs[idx].content = fmt.Sprintf("-- flyway-checksum: %d\n", flywayChecksum(string(source))) + outputs[idx].content
+			}
+		}
+		if cfg.EmitSourceComment {
+			version, _, err := splitFlywayFilename(name, cfg.migrationPrefix(), cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions())
+			if err != nil {
+				return fmt.Errorf("failed to convert filename %s: %w", path, err)
+			}
+			for idx := range outputs {
+				outputs[idx].content = fmt.Sprintf("-- source: %s (version %s)\n", path, version) + outputs[idx].content
+			}
+		}
+
+		for _, output := range outputs {
+			if cfg.ValidateOutput {
+				if err := ValidateGooseSQL(output.content); err != nil {
+					return fmt.Errorf("%s was converted to invalid goose SQL: %w", path, err)
+				}
+			}
+			if err := validateFile(path, output.content, cfg); err != nil {
+				if !cfg.ContinueOnError {
+					return err
+				}
+				cfg.logger().Warnf("%v", err)
+				p.report.Rejected = append(p.report.Rejected, RejectedFile{Source: path, Reason: err.Error()})
+				return nil
+			}
+		}
+
+		if p.inspect != nil {
+			contents := make([]string, len(outputs))
+			for idx, output := range outputs {
+				contents[idx] = output.content
+			}
+			p.inspect(path, text, contents)
+		}
+
+		gooseName, err := convertToGooseFilename(name, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to convert filename %s: %w", path, err)
+		}
+
+		version, err := strconv.ParseInt(strings.SplitN(gooseName, "_", 2)[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to convert filename %s: %w", path, err)
+		}
+		flywayVersion, _, err := splitFlywayFilename(name, cfg.migrationPrefix(), cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions())
 		if err != nil {
 			return fmt.Errorf("failed to convert filename %s: %w", path, err)
 		}
 
-		outputPath := filepath.Join(outputDir, gooseName)
-		if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+		for idx, output := range outputs {
+			output.source, output.target, output.version, output.flywayVersion = path, gooseName, version, flywayVersion
+			if cfg.SplitStatements {
+				// 在时间戳后面加上 4 位的序号，每个文件拆分出的迁移仍按原来的先后顺序执行
+				if version > (math.MaxInt64-9999)/10000 {
+					return fmt.Errorf("%s: goose version %d is too large to append a statement number", path, version)
+				}
+				_, description, _ := strings.Cut(gooseName, "_")
+				output.version = version*10000 + int64(idx+1)
+				output.target = fmt.Sprintf("%d_%s", output.version, description)
+			}
+
+			// 保留目录时 gooseName 为相对于输出目录的路径
+			if dir := filepath.Dir(path); cfg.PreserveDirectories && dir != "." {
+				output.target = filepath.ToSlash(filepath.Join(dir, output.target))
+			}
+			if cfg.YearDirectories {
+				output.target = gooseName[:4] + "/" + output.target
+			}
+
+			// 不同的 Flyway 文件可能生成相同的 goose 文件名，直接写出会覆盖前一个迁移
+			if previous, ok := p.targets[output.target]; ok {
+				switch {
+				case cfg.FilenameCollision == FilenameCollisionSuffix:
+					renamed := p.disambiguateTarget(output.target)
+					cfg.logger().Warnf("%s and %s both convert to %s, writing %s instead", previous, path, output.target, renamed)
+					output.target = renamed
+				case cfg.DryRun:
+					cfg.logger().Warnf("%s and %s both convert to %s", previous, path, output.target)
+				default:
+					return fmt.Errorf("%s and %s both convert to %s", previous, path, output.target)
+				}
+			}
+			p.targets[output.target] = path
+
+			// 文件名不同但版本相同时 goose 会拒绝执行(如 V1.2 和 V1.2.0 都转换为 ...0102000000)。
+			// 重新编号时不受影响，保留目录时各目录分别执行
+			if previous, ok := p.versions[output.version]; ok && previous != path && cfg.GooseNumbering == GooseNumberingTimestamp &&
+				!cfg.PreserveDirectories {
+				if !cfg.DryRun {
+					return fmt.Errorf("%s and %s both convert to goose version %d", previous, path, output.version)
+				}
+				cfg.logger().Warnf("%s and %s both convert to goose version %d", previous, path, output.version)
+			}
+			p.versions[output.version] = path
+
+			if cfg.GooseNumbering != GooseNumberingTimestamp || cfg.VersionIDColumnBits == 32 || p.ordered {
+				// 需要知道所有的版本后才能编号或排序，32 位的 version_id 列需要在写出任何文件前检查所有的版本
+				p.pending = append(p.pending, output)
+			} else if err := p.emit(output); err != nil {
+				return err
+			}
+		}
+
+		if cfg.StatementStats > 0 {
+			statements, err := splitDetailed(strings.NewReader(text), cfg.tokenizerOptions(), cfg.logger())
+			if err != nil {
+				return fmt.Errorf("failed to split %s: %w", path, err)
+			}
+			for _, stmt := range statements {
+				p.statements = append(p.statements, sourceStatement{source: path, Statement: stmt})
+			}
+		}
+
+		if cfg.DependencyCheck {
+			objects, err := collectMigrationObjects(path, version, text)
+			if err != nil {
+				return fmt.Errorf("failed to analyze %s: %w", path, err)
+			}
+			p.objects = append(p.objects, objects)
+		}
+
+		if len(cfg.Dialects) > 0 {
+			issues, err := CheckPortability(strings.NewReader(text), cfg.Dialects)
+			if err != nil {
+				return fmt.Errorf("failed to analyze %s: %w", path, err)
+			}
+			for _, issue := range issues {
+				issue.Source = path
+				cfg.logger().Warnf("%s: %s (line %d) is not supported by %s", path, issue.Feature, issue.Line, issue.Dialect)
+				p.report.DialectIssues = append(p.report.DialectIssues, issue)
+			}
+		}
+
+		if cfg.ShowDown {
+			down, complete, err := GenerateDown(text)
+			if err != nil {
+				return fmt.Errorf("failed to generate down for %s: %w", path, err)
+			}
+			if !complete {
+				cfg.logger().Warnf("%s cannot be fully reversed", path)
+			}
+			if down != "" {
+				cfg.logger().Infof("%s", down)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(undos))
+	for key := range undos {
+		if !pairedUndos[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cfg.logger().Warnf("undo script %s has no matching versioned migration, skipped", undos[key])
+		p.report.Skipped = append(p.report.Skipped, undos[key])
+	}
+	return nil
+}
+
+// logStatementStats 输出字节数最大的 cfg.StatementStats 条语句
+func (p *fsProcessor) logStatementStats() {
+	if p.cfg.StatementStats <= 0 || len(p.statements) == 0 {
+		return
+	}
+	sort.SliceStable(p.statements, func(i, j int) bool {
+		return p.statements[i].ByteSize > p.statements[j].ByteSize
+	})
+	if len(p.statements) > p.cfg.StatementStats {
+		p.statements = p.statements[:p.cfg.StatementStats]
+	}
+	p.cfg.logger().Infof("Largest statements:")
+	for _, stmt := range p.statements {
+		head, _, _ := strings.Cut(strings.TrimSpace(stmt.Text), "\n")
+		if runes := []rune(head); len(runes) > 60 {
+			head = string(runes[:60]) + "..."
+		}
+		p.cfg.logger().Infof("  %s: %d bytes, %d lines: %s", stmt.source, stmt.ByteSize, stmt.LineCount, head)
+	}
+}
+
+// convertParts 转换一个 Flyway 文件。SplitStatements 时每条语句转换为一个迁移，
+// undo 作为第一个迁移的 Down 部分：回滚时它最后执行，此时同一个文件中其它语句的迁移都已回滚
+func convertParts(text string, undo io.Reader, source string, cfg *Config) ([]convertedOutput, error) {
+	if !cfg.SplitStatements {
+		content, hasDown, err := convertFlywayToGooseWithUndo(strings.NewReader(text), undo, source, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []convertedOutput{{content: content, hasDown: hasDown}}, nil
+	}
+
+	statements, err := SplitWithConfig(strings.NewReader(text), cfg)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []convertedOutput
+	partCfg := cfg
+	for _, stmt := range statements {
+		if isEmptyOrComments(stmt) && !strings.Contains(stmt, "+goose") {
+			continue
+		}
+		content, hasDown, err := convertFlywayToGooseWithUndo(strings.NewReader(stmt), undo, source, partCfg)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, convertedOutput{content: content, hasDown: hasDown})
+
+		if undo != nil {
+			// undo 已经回滚了整个文件，其它语句不再推断 Down
+			undo = nil
+			copied := *cfg
+			copied.GenerateDown = false
+			partCfg = &copied
+		}
+	}
+	if len(outputs) > 9999 {
+		return nil, fmt.Errorf("%d statements cannot be split into separate migrations (max 9999)", len(outputs))
+	}
+	return outputs, nil
+}
+
+// emit 写出转换后的文件并记录到报告中
+func (p *fsProcessor) emit(output convertedOutput) error {
+	cfg := p.cfg
+	outputPath := filepath.Join(p.outputDir, output.target)
+	if p.sink != nil {
+		p.sink(ConvertedFile{
+			Source:  output.source,
+			Target:  output.target,
+			Version: output.version,
+			HasDown: output.hasDown,
+			Content: output.content,
+		})
+	} else if cfg.DryRun {
+		cfg.logger().Infof("Would convert: %s -> %s", output.source, output.target)
+	} else if cfg.Resume && isUpToDate(outputPath, output.content) {
+		p.checksums[output.target] = contentChecksum(output.content)
+		p.report.Resumed = append(p.report.Resumed, output.target)
+		cfg.logger().Infof("Up to date: %s -> %s", output.source, output.target)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(outputPath, []byte(output.content), 0644); err != nil {
 			return fmt.Errorf("failed to write %s: %w", outputPath, err)
 		}
+		p.checksums[output.target] = contentChecksum(output.content)
+		cfg.logger().Infof("Converted: %s -> %s", output.source, output.target)
+	}
+
+	p.report.Written = append(p.report.Written, ConvertedFile{
+		Source:  output.source,
+		Target:  output.target,
+		Version: output.version,
+		HasDown: output.hasDown,
+	})
+	return nil
+}
+
+// emitSequential 按 Flyway 版本的顺序将等待写出的文件重新编号为 00001、00002 ... 后写出，
+// GooseNumberingStride 时序号乘以 NumberingStride
+func (p *fsProcessor) emitSequential() error {
+	stride := p.cfg.numberingStride()
+	p.sortPending()
+	if limit := int64(math.MaxInt64) / int64(len(p.pending)+1); stride > limit {
+		return fmt.Errorf("numbering stride %d is too large", stride)
+	}
+
+	p.report.Numbering = map[string][]int64{}
+	p.report.FlywayVersions = map[string]int64{}
+	for idx := range p.pending {
+		output := &p.pending[idx]
+		sequence := int64(idx+1) * stride
+		dir, name := filepath.Split(output.target)
+		_, description, _ := strings.Cut(name, "_")
+		output.target = filepath.ToSlash(dir) + fmt.Sprintf("%05d_%s", sequence, description)
+		output.version = sequence
+		p.report.Numbering[output.source] = append(p.report.Numbering[output.source], sequence)
+		p.report.FlywayVersions[strings.ReplaceAll(output.flywayVersion, "_", ".")] = sequence
+	}
+	return p.emitPending()
+}
+
+// sortPending 按 Flyway 版本的顺序排列等待写出的文件，编码后的时间戳可能与 Flyway 的顺序不同，
+// Flyway 版本相同时(如拆分出的语句)仍按时间戳排序
+func (p *fsProcessor) sortPending() {
+	sort.SliceStable(p.pending, func(i, j int) bool {
+		if c := compareFlywayVersions(p.pending[i].flywayVersion, p.pending[j].flywayVersion); c != 0 {
+			return c < 0
+		}
+		return p.pending[i].version < p.pending[j].version
+	})
+}
+
+// emitPending 检查所有等待写出的文件的版本号都能保存到 version_id 列中之后再依次写出，
+// 不会因为后面的版本号超出范围而只写出一部分文件
+func (p *fsProcessor) emitPending() error {
+	if p.ordered {
+		p.sortPending()
+	}
+	for _, output := range p.pending {
+		if err := checkVersionIDBits(output.version, p.cfg.VersionIDColumnBits); err != nil {
+			return fmt.Errorf("%s: %w", output.source, err)
+		}
+	}
+	for _, output := range p.pending {
+		if err := p.emit(output); err != nil {
+			return err
+		}
+	}
+	p.pending = nil
+	return nil
+}
+
+// validateFile 依次调用 cfg.FileValidators 检查转换后的文件
+func validateFile(path, content string, cfg *Config) error {
+	for _, validate := range cfg.FileValidators {
+		if err := validate(path, content); err != nil {
+			return fmt.Errorf("%s rejected: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// checkVersionIDBits 检查 version 是否能保存到 bits 位的 version_id 列中，bits 为 0 时按 64 位处理
+func checkVersionIDBits(version int64, bits int) error {
+	if bits == 32 && (version > math.MaxInt32 || version < math.MinInt32) {
+		return fmt.Errorf("goose version %d does not fit in a 32-bit version_id column (max %d), change the column to BIGINT or use sequential numbering", version, int64(math.MaxInt32))
+	}
+	return nil
+}
+
+// disambiguateTarget 在 gooseName 的描述后加上数字后缀，返回还没有被使用的文件名
+func (p *fsProcessor) disambiguateTarget(gooseName string) string {
+	base := strings.TrimSuffix(gooseName, ".sql")
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s_%d.sql", base, n)
+		if _, ok := p.targets[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// readSource 读取迁移脚本的内容，配置了 include 指令时内联被引用的文件，
+// 模板文件用 TemplateRenderer 渲染，之后替换其中的占位符
+func (p *fsProcessor) readSource(fsys fs.FS, path string) (string, error) {
+	text, err := p.readRawSource(fsys, path)
+	if err != nil {
+		return "", err
+	}
+	if _, templated := p.cfg.templateScriptName(path); templated {
+		text, err = p.cfg.TemplateRenderer(path, text)
+		if err != nil {
+			return "", fmt.Errorf("failed to render %s: %w", path, err)
+		}
+	}
+	return substitutePlaceholders(text, path, p.cfg, p.now)
+}
+
+func (p *fsProcessor) readRawSource(fsys fs.FS, path string) (string, error) {
+	if len(p.cfg.IncludeDirectives) > 0 {
+		return readWithIncludes(fsys, path, p.cfg.IncludeDirectives)
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(utfbom.SkipOnly(file))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// collectUndoFiles 返回文件系统 root 目录下 Flyway undo 脚本(U1.2__xxx.sql)的 版本号 -> 路径
+func collectUndoFiles(fsys fs.FS, root string, cfg *Config) (map[string]string, error) {
+	return collectVersionedFiles(fsys, root, "U", cfg)
+}
+
+// collectVersionedFiles 返回文件系统 root 目录下以 prefix 标记版本号的脚本的 版本号 -> 路径
+func collectVersionedFiles(fsys fs.FS, root, prefix string, cfg *Config) (map[string]string, error) {
+	scripts := map[string]string{}
+	err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, templated := cfg.templateScriptName(path)
+		if d.IsDir() || !isFlywayScript(name, prefix, cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions()) {
+			return nil
+		}
+		if templated && cfg.TemplateRenderer == nil {
+			return nil
+		}
 
-		fmt.Printf("Converted: %s -> %s\n", path, gooseName)
+		key, err := flywayVersionKey(name, prefix, cfg)
+		if err != nil {
+			cfg.logger().Warnf("skip script %s: %v", path, err)
+			return nil
+		}
+		scripts[key] = path
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return scripts, nil
+}
+
+// warnUnpairedRollbacks 对 RollbackDir 中没有对应迁移的回滚脚本输出警告
+func (p *fsProcessor) warnUnpairedRollbacks() {
+	keys := make([]string, 0, len(p.rollbacks))
+	for key := range p.rollbacks {
+		if !p.pairedRollbacks[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p.cfg.logger().Warnf("rollback script %s has no matching versioned migration, skipped", p.rollbacks[key])
+	}
+}
+
+// flywayVersionKey 返回 V/U 文件名中的版本号转换后的 Goose 版本号，用于配对 V 文件和 U 文件
+func flywayVersionKey(name, prefix string, cfg *Config) (string, error) {
+	version, _, err := splitFlywayFilename(name, prefix, cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions())
+	if err != nil {
+		return "", err
+	}
+	return convertVersion(version, cfg.BaseYear, cfg.VersionStrategy, cfg.VersionScheme)
+}
+
+// isFlywayFilename 检查文件名是否符合 cfg 中前缀、分隔符和扩展名设置的 Flyway 格式
+func isFlywayFilename(name string, cfg *Config) bool {
+	return isFlywayScript(name, cfg.migrationPrefix(), cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions())
+}
+
+// isFlywayScript 检查文件名是否为以 prefix(如 V 或 U)标记版本号、以 separator 分隔版本号和描述、
+// 扩展名在 extensions 中的 Flyway 脚本
+func isFlywayScript(name, prefix, separator string, order NameOrder, extensions []string) bool {
+	name = filepath.Base(name)
+	if _, ok := trimScriptExtension(name, extensions); !ok {
+		return false
+	}
+	if order == DescriptionFirst {
+		return strings.Contains(name, separator+prefix)
+	}
+	return strings.HasPrefix(name, prefix) &&
+		strings.Contains(name, separator)
 }
 
-// isFlywayFilename 检查文件名是否符合 Flyway 格式
-func isFlywayFilename(name string) bool {
+// flywaySkipReason 返回 name 不是 Flyway 迁移脚本的原因，用于诊断前缀等配置错误
+func flywaySkipReason(name, prefix, separator string, order NameOrder, extensions []string) string {
 	name = filepath.Base(name)
-	return strings.HasPrefix(name, "V") &&
-		strings.Contains(name, "__") &&
-		strings.HasSuffix(name, ".sql")
+	_, hasExtension := trimScriptExtension(name, extensions)
+	switch {
+	case !hasExtension:
+		return fmt.Sprintf("not a %s file", strings.Join(extensions, "/"))
+	case !strings.Contains(name, separator):
+		return fmt.Sprintf("missing %s separator", separator)
+	case order == DescriptionFirst:
+		return fmt.Sprintf("missing %s%s version suffix", separator, prefix)
+	default:
+		return fmt.Sprintf("missing %s prefix", prefix)
+	}
+}
+
+// splitFlywayFilename 按 order 在 separator 处将文件名拆分为版本号(不含 prefix)和描述
+func splitFlywayFilename(name, prefix, separator string, order NameOrder, extensions []string) (version, description string, err error) {
+	base, _ := trimScriptExtension(filepath.Base(name), extensions)
+	if order == DescriptionFirst {
+		idx := strings.LastIndex(base, separator)
+		if idx < 0 {
+			return "", "", fmt.Errorf("invalid Flyway filename format")
+		}
+		description, version = base[:idx], base[idx+len(separator):]
+		// 分隔符为 "_" 时 add__V1 的描述为 "add_"，去掉多余的分隔符
+		for separator != "" && strings.HasSuffix(description, separator) {
+			description = strings.TrimSuffix(description, separator)
+		}
+	} else {
+		parts := strings.SplitN(base, separator, 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("invalid Flyway filename format")
+		}
+		version, description = parts[0], parts[1]
+		// 分隔符为 "_" 时 V1__add 的描述为 "_add"，去掉多余的分隔符
+		for separator != "" && strings.HasPrefix(description, separator) {
+			description = strings.TrimPrefix(description, separator)
+		}
+	}
+
+	return strings.TrimPrefix(version, prefix), description, nil
 }
 
 // convertToGooseFilename 将 Flyway 文件名转换为 Goose 格式
-func convertToGooseFilename(flywayName string, baseYear string) (string, error) {
-	base := strings.TrimSuffix(filepath.Base(flywayName), ".sql")
-	parts := strings.SplitN(base, "__", 2)
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid Flyway filename format")
+// cfg.DescriptionTransform 不为空时先对描述进行转换，转换结果必须是安全的文件名
+func convertToGooseFilename(flywayName string, cfg *Config) (string, error) {
+	versionStr, description, err := splitFlywayFilename(flywayName, cfg.migrationPrefix(), cfg.migrationSeparator(), cfg.NameOrder, cfg.extensions())
+	if err != nil {
+		return "", err
+	}
+	if versionStr == "" {
+		return "", fmt.Errorf("missing version number in filename '%s'", filepath.Base(flywayName))
+	}
+
+	if cfg.DescriptionTransform != nil {
+		transformed := cfg.DescriptionTransform(description)
+		if transformed == "" {
+			return "", fmt.Errorf("DescriptionTransform returned an empty description for '%s'", filepath.Base(flywayName))
+		}
+		if sanitized := sanitizeDescription(transformed); sanitized != transformed {
+			return "", fmt.Errorf("DescriptionTransform returned %q for '%s', which is not a safe filename (would become %q)", transformed, filepath.Base(flywayName), sanitized)
+		}
+		description = transformed
 	}
+	return gooseFilename(versionStr, description, cfg.BaseYear, cfg.VersionStrategy, cfg.VersionScheme)
+}
+
+// GooseFilename 根据 Flyway 版本号和描述生成 Goose 文件名，
+// 适用于版本号和描述来自数据库(如 flyway_schema_history)而不是文件名的场景
+func GooseFilename(version, description, baseYear string) (string, error) {
+	return gooseFilename(version, description, baseYear, VersionStrategyAuto, nil)
+}
 
-	versionStr := strings.TrimPrefix(parts[0], "V")
-	timestamp, err := convertToGooseTimestamp(versionStr, baseYear)
+func gooseFilename(version, description, baseYear, strategy string, scheme *VersionScheme) (string, error) {
+	timestamp, err := convertVersion(version, baseYear, strategy, scheme)
 	if err != nil {
 		return "", err
 	}
+	return fmt.Sprintf("%s_%s.sql", timestamp, sanitizeDescription(description)), nil
+}
 
-	description := strings.Map(func(r rune) rune {
+// sanitizeDescription 只保留描述中的字母、数字和 '_'，'-' 转为 '_'
+func sanitizeDescription(description string) string {
+	return strings.Map(func(r rune) rune {
 		switch {
 		case r == '_' || r == '-':
 			return '_'
@@ -310,61 +1836,60 @@ func convertToGooseFilename(flywayName string, baseYear string) (string, error)
 		default:
 			return -1
 		}
-	}, parts[1])
-
-	return fmt.Sprintf("%s_%s.sql", timestamp, description), nil
+	}, description)
 }
 
 // convertToGooseTimestamp 将 Flyway 版本号转换为 Goose 时间戳
 func convertToGooseTimestamp(versionStr string, baseYear string) (string, error) {
-	major, minor, patch, err := parseFlywayVersion(versionStr)
-	if err != nil {
-		return "", err
-	}
-
-	timestamp := fmt.Sprintf("%02d%02d%06d",
-		major,
-		minor,
-		patch)
-
-	if len(timestamp) != 10 {
-		return "", fmt.Errorf("invalid timestamp length")
-	}
-	return baseYear + timestamp, nil
+	return convertVersion(versionStr, baseYear, VersionStrategyAuto, nil)
 }
 
-// parseFlywayVersion 解析 Flyway 版本号
-func parseFlywayVersion(versionStr string) (major, minor, patch int, err error) {
-	parts := strings.Split(versionStr, ".")
-	if len(parts) > 3 {
-		return 0, 0, 0, fmt.Errorf("version format should be Vx.x.xxx")
-	}
-	if len(parts) == 1 {
-		parts = strings.Split(versionStr, "_")
-		if len(parts) > 3 {
-			return 0, 0, 0, fmt.Errorf("version format should be Vx.x.xxx")
+// convertVersion 按 strategy 将 Flyway 版本号转换为 Goose 时间戳，不是时间戳的版本号按 scheme 编码，
+// scheme 为 nil 时使用 DefaultVersionScheme
+func convertVersion(versionStr, baseYear, strategy string, scheme *VersionScheme) (string, error) {
+	switch strategy {
+	case "", VersionStrategyAuto:
+		if isTimestampVersion(versionStr) {
+			return versionStr, nil
+		}
+	case VersionStrategyTimestamp:
+		if !isTimestampVersion(versionStr) {
+			return "", fmt.Errorf("version %q is not a 14-digit timestamp", versionStr)
 		}
+		return versionStr, nil
+	case VersionStrategySemantic:
+	default:
+		return "", fmt.Errorf("unknown version strategy %q", strategy)
 	}
 
-	major, err = strconv.Atoi(parts[0])
-	if err != nil || major < 1 || major > 12 {
-		return 0, 0, 0, fmt.Errorf("major version must be 1-12")
+	if scheme == nil {
+		scheme = &DefaultVersionScheme
 	}
-	if len(parts) == 1 {
-		return major, 1, 0, nil
+	values, err := scheme.parse(versionStr)
+	if err != nil {
+		return "", err
 	}
+	return scheme.encode(values, baseYear)
+}
 
-	minor, err = strconv.Atoi(parts[1])
-	if err != nil || minor < 0 || minor > 31 {
-		return 0, 0, 0, fmt.Errorf("minor version must be 1-31")
+// isTimestampVersion 检查版本号是否为 14 位数字的时间戳(yyyyMMddHHmmss)
+func isTimestampVersion(versionStr string) bool {
+	if len(versionStr) != 14 {
+		return false
 	}
-	if len(parts) == 2 {
-		return major, minor, 0, nil
+	for _, r := range versionStr {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
+	return true
+}
 
-	patch, err = strconv.Atoi(parts[2])
-	if err != nil || patch < 1 || patch > 999999 {
-		return 0, 0, 0, fmt.Errorf("patch version must be 1-999999")
+// parseFlywayVersion 按 DefaultVersionScheme 解析 Flyway 版本号
+func parseFlywayVersion(versionStr string) (major, minor, patch int, err error) {
+	values, err := DefaultVersionScheme.parse(versionStr)
+	if err != nil {
+		return 0, 0, 0, err
 	}
-	return major, minor, patch, nil
+	return values[0], values[1], values[2], nil
 }
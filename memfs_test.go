@@ -0,0 +1,18 @@
+package goflyway
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// TestMemFS 测试内存文件系统满足 fs.FS 的约定
+func TestMemFS(t *testing.T) {
+	fsys := memFS{
+		"20000100000000_init.sql":      []byte("-- +goose Up\nCREATE TABLE a (id INT);\n"),
+		"2000/20000200000000_seed.sql": []byte("-- +goose Up\nINSERT INTO a VALUES (1);\n"),
+		"2000/sub/empty.sql":           nil,
+	}
+	if err := fstest.TestFS(fsys, "20000100000000_init.sql", "2000/20000200000000_seed.sql", "2000/sub/empty.sql"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+package goflyway
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+)
+
+// transactionalDialects 支持在事务中执行 DDL 的数据库类型 -> goose 方言
+var transactionalDialects = map[string]database.Dialect{
+	"postgres":  database.DialectPostgres,
+	"sqlite3":   database.DialectSQLite3,
+	"sqlserver": database.DialectMSSQL,
+}
+
+// migrateInSingleTransaction 在同一个事务中执行 migrationsDir 中所有待执行的迁移，
+// 任何一条语句失败时整个批次都会回滚，返回执行后的数据库版本
+func migrateInSingleTransaction(ctx context.Context, db *sql.DB, migrationsDir, driver string) (int64, error) {
+	dialect, ok := transactionalDialects[DriverFamily(driver)]
+	if !ok {
+		return 0, fmt.Errorf("single transaction is not supported for driver %q: DDL statements cannot be rolled back", driver)
+	}
+
+	// GetDBVersionContext 按 goose 的全局方言创建版本表，默认为 Postgres 的语法
+	if err := goose.SetDialect(gooseDialect(driver)); err != nil {
+		return 0, fmt.Errorf("failed to set dialect: %w", err)
+	}
+
+	// 确保版本表存在，并读取当前版本
+	current, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get db version: %w", err)
+	}
+
+	migrations, err := goose.CollectMigrations(migrationsDir, current, math.MaxInt64)
+	if errors.Is(err, goose.ErrNoMigrationFiles) {
+		// 没有待执行的迁移
+		return current, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+
+	store, err := database.NewStore(dialect, goose.TableName())
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	version := current
+	for _, m := range migrations {
+		if err := applyInTransaction(ctx, tx, store, m); err != nil {
+			return 0, err
+		}
+		version = m.Version
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return version, nil
+}
+
+// applyInTransaction 在 tx 中执行一个迁移文件的 Up 部分并记录版本
+func applyInTransaction(ctx context.Context, tx *sql.Tx, store database.Store, m *goose.Migration) error {
+	content, err := os.ReadFile(m.Source)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", m.Source, err)
+	}
+	if gooseNoTransactionRE.Match(content) {
+		return fmt.Errorf("migration %s is marked NO TRANSACTION and cannot run in a single transaction", m.Source)
+	}
+
+	statements, err := gooseUpStatements(string(content))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", m.Source, err)
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", m.Source, err)
+		}
+	}
+
+	err = store.Insert(ctx, tx, database.InsertRequest{Version: m.Version})
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", m.Source, err)
+	}
+	return nil
+}
+
+// gooseUpStatements 返回 goose 迁移文件 Up 部分中的各条语句，
+// StatementBegin/StatementEnd 之间的内容作为一条语句
+func gooseUpStatements(content string) ([]string, error) {
+	var up strings.Builder
+	inUp := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		switch fields := strings.Fields(line); {
+		case len(fields) == 3 && fields[0] == "--" && fields[1] == "+goose" && fields[2] == "Up":
+			inUp = true
+			continue
+		case len(fields) == 3 && fields[0] == "--" && fields[1] == "+goose" && fields[2] == "Down":
+			inUp = false
+			continue
+		}
+		if inUp {
+			up.WriteString(line)
+		}
+	}
+
+	var statements []string
+	blocks, isBlock, err := SplitByDelimiter(strings.NewReader(up.String()), "goose")
+	if err != nil {
+		return nil, err
+	}
+	for idx, block := range blocks {
+		if isBlock[idx] {
+			// 去掉首尾的 StatementBegin/StatementEnd 行
+			lines := strings.Split(block, "\n")
+			statements = append(statements, strings.Join(lines[1:len(lines)-1], "\n"))
+			continue
+		}
+
+		parts, err := Split(strings.NewReader(block))
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range parts {
+			if !isEmptyOrComments(stmt) {
+				statements = append(statements, stmt)
+			}
+		}
+	}
+	return statements, nil
+}
@@ -0,0 +1,245 @@
+package goflyway
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestConvertAndMigrate_SingleTransaction(t *testing.T) {
+	inputDir := t.TempDir()
+	files := map[string]string{
+		"V1__create_a.sql": "CREATE TABLE a (id INT);\n",
+		"V2__create_b.sql": "CREATE TABLE b (id INT);\nINSERT INTO missing_table VALUES (1);\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	cfg := &Config{
+		InputPath:         inputDir,
+		OutputDir:         t.TempDir(),
+		BaseYear:          "2000",
+		DBDriver:          "sqlite3",
+		DBConnString:      dbPath,
+		SingleTransaction: true,
+	}
+	err := ConvertAndMigrate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "missing_table") {
+		t.Fatalf("expected error from failing migration, got %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// V1 也应该被回滚
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('a', 'b')`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected tables to be rolled back, found %d", count)
+	}
+	err = db.QueryRow(`SELECT COUNT(*) FROM goose_db_version WHERE version_id > 0`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected no versions recorded, found %d", count)
+	}
+
+	// 修复失败的迁移后可以完整执行
+	err = os.WriteFile(filepath.Join(inputDir, "V2__create_b.sql"), []byte("CREATE TABLE b (id INT);\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertAndMigrate(cfg); err != nil {
+		t.Fatalf("ConvertAndMigrate() error = %v", err)
+	}
+	err = db.QueryRow(`SELECT COUNT(*) FROM goose_db_version WHERE version_id > 0`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 versions recorded, found %d", count)
+	}
+}
+
+// TestConvertAndMigrateDB_SingleTransactionFirst 测试在新的 sqlite 数据库上首先以单事务模式执行，
+// 不依赖其它测试设置的 goose 方言
+func TestConvertAndMigrateDB_SingleTransactionFirst(t *testing.T) {
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "V1__create_a.sql"), []byte("CREATE TABLE a (id INT);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	cfg := &Config{
+		InputPath:         inputDir,
+		OutputDir:         t.TempDir(),
+		BaseYear:          "2000",
+		DBDriver:          "sqlite3",
+		SingleTransaction: true,
+	}
+	if err := ConvertAndMigrateDB(cfg, db); err != nil {
+		t.Fatalf("ConvertAndMigrateDB() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM goose_db_version WHERE version_id = 20000101000000`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected version 20000101000000 to be recorded, found %d", count)
+	}
+}
+
+func TestConvertAndMigrate_SingleTransactionMySQL(t *testing.T) {
+	cfg := &Config{
+		InputPath:         "testdata",
+		OutputDir:         t.TempDir(),
+		BaseYear:          "2000",
+		DBDriver:          "mysql",
+		DBConnString:      "root@tcp(127.0.0.1:3306)/test",
+		SingleTransaction: true,
+	}
+	err := ConvertAndMigrate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "single transaction is not supported") {
+		t.Errorf("expected unsupported error, got %v", err)
+	}
+}
+
+func TestGooseUpStatements(t *testing.T) {
+	content := `-- +goose Up
+CREATE TABLE t (id INT);
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS INT AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE t;
+`
+	statements, err := gooseUpStatements(content)
+	if err != nil {
+		t.Fatalf("gooseUpStatements() error = %v", err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %q", len(statements), statements)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(statements[1]), "CREATE FUNCTION") ||
+		!strings.HasSuffix(statements[1], "LANGUAGE plpgsql;") {
+		t.Errorf("unexpected block statement: %q", statements[1])
+	}
+}
+
+func TestConvertAndMigrateDB(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "V1__create_a.sql"), []byte("CREATE TABLE a (id INT);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, single := range []bool{false, true} {
+		cfg := &Config{
+			InputPath:         inputDir,
+			OutputDir:         t.TempDir(),
+			BaseYear:          "2000",
+			DBDriver:          "sqlite3",
+			SingleTransaction: single,
+		}
+		if err := ConvertAndMigrateDB(cfg, db); err != nil {
+			t.Fatalf("ConvertAndMigrateDB(SingleTransaction=%v) error = %v", single, err)
+		}
+	}
+
+	// 调用者提供的 db 不会被关闭，迁移结果在同一个内存数据库中
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'a'`).Scan(&count)
+	if err != nil {
+		t.Fatalf("db should still be usable: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected table a to be created, found %d", count)
+	}
+}
+
+// TestConvertToFS 测试将内存中转换得到的 fs.FS 直接交给 goose 执行
+func TestConvertToFS(t *testing.T) {
+	inputDir := t.TempDir()
+	files := map[string]string{
+		"V1__create_a.sql": "CREATE TABLE a (id INT);\n",
+		"V2__seed_a.sql":   "INSERT INTO a VALUES (1);\nINSERT INTO a VALUES (2);\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fsys, err := ConvertToFS(inputDir, "2000")
+	if err != nil {
+		t.Fatalf("ConvertToFS() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	goose.SetBaseFS(fsys)
+	t.Cleanup(func() { goose.SetBaseFS(nil) })
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "."); err != nil {
+		t.Fatalf("goose.Up() error = %v", err)
+	}
+
+	version, err := goose.GetDBVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 20000201000000 {
+		t.Errorf("GetDBVersion() = %d, want 20000201000000", version)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM a`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("rows in a = %d, want 2", count)
+	}
+}
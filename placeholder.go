@@ -0,0 +1,102 @@
+package goflyway
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// placeholderRE 匹配 ${name} 形式的 Flyway 占位符
+var placeholderRE = regexp.MustCompile(`\$\{([^${}]+)\}`)
+
+// flywayPlaceholderPrefix 是 Flyway 内置占位符的前缀
+const flywayPlaceholderPrefix = "flyway:"
+
+// PlaceholderTimestampFormat 是 ${flyway:timestamp} 的格式，与 Flyway 相同
+const PlaceholderTimestampFormat = "2006-01-02 15:04:05"
+
+// placeholderContext 是解析内置占位符时需要的信息
+type placeholderContext struct {
+	cfg      *Config
+	filename string    // 迁移脚本的文件名，不含目录
+	now      time.Time // 本次转换的时间
+}
+
+// builtinPlaceholders Flyway 内置占位符的解析函数，键为 flyway: 之后的小写名称，返回 false 表示无法确定其值
+var builtinPlaceholders = map[string]func(ctx *placeholderContext) (string, bool){
+	"filename": func(ctx *placeholderContext) (string, bool) {
+		return ctx.filename, true
+	},
+	"timestamp": func(ctx *placeholderContext) (string, bool) {
+		return ctx.now.Format(PlaceholderTimestampFormat), true
+	},
+	"user": func(ctx *placeholderContext) (string, bool) {
+		user, _ := dsnUserAndDatabase(ctx.cfg.DBConnString)
+		return user, user != ""
+	},
+	"database": func(ctx *placeholderContext) (string, bool) {
+		_, database := dsnUserAndDatabase(ctx.cfg.DBConnString)
+		return database, database != ""
+	},
+	"defaultschema": func(ctx *placeholderContext) (string, bool) {
+		return ctx.cfg.TargetSchema, ctx.cfg.TargetSchema != ""
+	},
+}
+
+// substitutePlaceholders 替换 text 中的占位符，sourcePath 为迁移脚本的路径。
+// cfg.Placeholders 中的值优先；${flyway:xxx} 由内置解析函数处理，无法解析时输出警告并保留原样；
+// 其它占位符只在设置了 cfg.Placeholders 时替换，没有对应的值时报错。
+// 设置了 cfg.PlaceholderDefaultSeparator 时，带默认值的占位符在以上情况下都使用默认值
+func substitutePlaceholders(text, sourcePath string, cfg *Config, now time.Time) (string, error) {
+	if !strings.Contains(text, "${") {
+		return text, nil
+	}
+
+	ctx := &placeholderContext{cfg: cfg, filename: path.Base(sourcePath), now: now}
+	var firstErr error
+	result := placeholderRE.ReplaceAllStringFunc(text, func(match string) string {
+		name := match[2 : len(match)-1]
+		if value, ok := cfg.Placeholders[name]; ok {
+			return value
+		}
+
+		defaultValue, hasDefault := "", false
+		if cfg.PlaceholderDefaultSeparator != "" {
+			name, defaultValue, hasDefault = strings.Cut(name, cfg.PlaceholderDefaultSeparator)
+			if value, ok := cfg.Placeholders[name]; ok {
+				return value
+			}
+		}
+
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, flywayPlaceholderPrefix) {
+			if cfg.DisableBuiltinPlaceholders {
+				return match
+			}
+			if resolve, ok := builtinPlaceholders[strings.TrimPrefix(lower, flywayPlaceholderPrefix)]; ok {
+				if value, ok := resolve(ctx); ok {
+					return value
+				}
+			}
+			if hasDefault {
+				return defaultValue
+			}
+			cfg.logger().Warnf("%s: unresolved placeholder %s", sourcePath, match)
+			return match
+		}
+
+		if hasDefault {
+			return defaultValue
+		}
+		if cfg.Placeholders != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: unresolved placeholder %s", sourcePath, match)
+		}
+		return match
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
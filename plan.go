@@ -0,0 +1,66 @@
+package goflyway
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/pressly/goose/v3"
+)
+
+// MigrationPlan 描述在数据库上执行 goose up 将会做什么，生成计划时不会执行任何迁移
+type MigrationPlan struct {
+	CurrentVersion int64              // 数据库当前的 goose 版本
+	TargetVersion  int64              // 执行完所有待执行迁移后的版本
+	Pending        []PendingMigration // 按版本号排序的待执行迁移
+}
+
+// PendingMigration 表示一个尚未执行的迁移文件
+type PendingMigration struct {
+	Version int64
+	Source  string
+}
+
+// PlanMigrations 读取 db 当前的 goose 版本，列出 migrationsDir 中版本号高于它的迁移。
+// 只读取 goose 版本表，不会创建它，版本表不存在时当前版本为 0
+func PlanMigrations(db *sql.DB, driver, migrationsDir string) (*MigrationPlan, error) {
+	if err := goose.SetDialect(gooseDialect(driver)); err != nil {
+		return nil, fmt.Errorf("failed to set dialect: %w", err)
+	}
+
+	current, err := readDBVersion(context.Background(), db, driver, goose.TableName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db version: %w", err)
+	}
+
+	migrations, err := goose.CollectMigrations(migrationsDir, current, math.MaxInt64)
+	if err != nil && !errors.Is(err, goose.ErrNoMigrationFiles) {
+		return nil, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+
+	plan := &MigrationPlan{
+		CurrentVersion: current,
+		TargetVersion:  current,
+	}
+	for _, m := range migrations {
+		plan.Pending = append(plan.Pending, PendingMigration{Version: m.Version, Source: m.Source})
+		plan.TargetVersion = m.Version
+	}
+	return plan, nil
+}
+
+// readDBVersion 返回 goose 版本表中最大的版本号，版本表不存在时返回 0。
+// goose.GetDBVersion 会在版本表不存在时创建它，这里只执行查询
+func readDBVersion(ctx context.Context, db *sql.DB, driver, gooseTable string) (int64, error) {
+	if !gooseTableExists(ctx, db, driver, gooseTable) {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	query := fmt.Sprintf("SELECT MAX(version_id) FROM %s", gooseTableName(driver, gooseTable))
+	if err := db.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version.Int64, nil
+}
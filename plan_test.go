@@ -0,0 +1,105 @@
+package goflyway
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+)
+
+func TestPlanMigrations(t *testing.T) {
+	migrationsDir := t.TempDir()
+	for _, name := range []string{
+		"20000101000000_a.sql",
+		"20000102000003_b.sql",
+		"20000103000000_c.sql",
+	} {
+		err := os.WriteFile(filepath.Join(migrationsDir, name), []byte("-- +goose Up\nSELECT 1;\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.UpTo(db, migrationsDir, 20000101000000); err != nil {
+		t.Fatalf("goose.UpTo() error = %v", err)
+	}
+
+	plan, err := PlanMigrations(db, "sqlite3", migrationsDir)
+	if err != nil {
+		t.Fatalf("PlanMigrations() error = %v", err)
+	}
+
+	expected := &MigrationPlan{
+		CurrentVersion: 20000101000000,
+		TargetVersion:  20000103000000,
+		Pending: []PendingMigration{
+			{Version: 20000102000003, Source: filepath.Join(migrationsDir, "20000102000003_b.sql")},
+			{Version: 20000103000000, Source: filepath.Join(migrationsDir, "20000103000000_c.sql")},
+		},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("PlanMigrations() = %+v, want %+v", plan, expected)
+	}
+
+	// 生成计划不应执行任何迁移
+	version, err := goose.GetDBVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 20000101000000 {
+		t.Errorf("db version changed to %d", version)
+	}
+}
+
+// TestPlanMigrations_NoVersionTable 测试版本表不存在时当前版本为 0，并且生成计划不会创建版本表
+func TestPlanMigrations_NoVersionTable(t *testing.T) {
+	migrationsDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(migrationsDir, "20000101000000_a.sql"), []byte("-- +goose Up\nSELECT 1;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// sqlite 是 sqlite3 的别名
+	plan, err := PlanMigrations(db, "sqlite", migrationsDir)
+	if err != nil {
+		t.Fatalf("PlanMigrations() error = %v", err)
+	}
+	expected := &MigrationPlan{
+		CurrentVersion: 0,
+		TargetVersion:  20000101000000,
+		Pending: []PendingMigration{
+			{Version: 20000101000000, Source: filepath.Join(migrationsDir, "20000101000000_a.sql")},
+		},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("PlanMigrations() = %+v, want %+v", plan, expected)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", goose.TableName()).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("PlanMigrations() created the goose version table")
+	}
+}
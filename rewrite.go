@@ -0,0 +1,236 @@
+package goflyway
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// canonicalizeWhitespace 规范化语句中的空白：
+// 行内连续的空格/制表符合并为一个空格，去除行尾空白，行首缩进保持不变。
+// 借助 Tokenizer 跳过字符串、注释和 $$ 代码块，这些内容原样保留。
+func canonicalizeWhitespace(stmt string) (string, error) {
+	var result strings.Builder
+	tokenizer := NewTokenizer(strings.NewReader(stmt))
+
+	atLineStart := true
+	pendingSpace := false
+
+	writeSpace := func(r rune) {
+		switch r {
+		case '\r', '\n':
+			// 行尾空白直接丢弃
+			pendingSpace = false
+			atLineStart = true
+			result.WriteRune(r)
+		default:
+			if atLineStart {
+				result.WriteRune(r)
+			} else {
+				pendingSpace = true
+			}
+		}
+	}
+	writeText := func(s string) {
+		if pendingSpace {
+			result.WriteByte(' ')
+			pendingSpace = false
+		}
+		result.WriteString(s)
+		// 行注释会把换行符一起读入
+		atLineStart = strings.HasSuffix(s, "\n")
+	}
+
+	for {
+		token, err := tokenizer.NextToken()
+		if token.Value != "" {
+			switch {
+			case isBlankToken(token.Value):
+				for _, r := range token.Value {
+					writeSpace(r)
+				}
+			case token.Type == TokenText && isWordWithTrailingSpace(token.Value):
+				// AS/DO 后面没有 $$ 代码块时，会把后续空白一起读入
+				word := strings.TrimRight(token.Value, " \t\r\n")
+				writeText(word)
+				for _, r := range token.Value[len(word):] {
+					writeSpace(r)
+				}
+			default:
+				writeText(token.Value)
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+	}
+
+	return result.String(), nil
+}
+
+func isBlankToken(s string) bool {
+	return s == " " || s == "\t" || s == "\r" || s == "\n"
+}
+
+func isWordWithTrailingSpace(s string) bool {
+	word := strings.TrimRight(s, " \t\r\n")
+	if word == "" || len(word) == len(s) {
+		return false
+	}
+	for _, r := range word {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlKeywords 转换大小写时识别的 SQL 关键字
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		ADD ALL ALTER AND AS ASC BEGIN BETWEEN BY CASCADE CASE CHECK COLUMN COMMENT COMMIT CONSTRAINT
+		CREATE DEFAULT DELETE DESC DISTINCT DO DROP ELSE END EXISTS FOREIGN FROM FUNCTION GRANT
+		GROUP HAVING IF IN INDEX INNER INSERT INTO IS JOIN KEY LANGUAGE LEFT LIKE LIMIT NOT NULL
+		ON OR ORDER OUTER PRIMARY PROCEDURE REFERENCES RENAME REPLACE RETURN RETURNS REVOKE RIGHT
+		ROLLBACK SCHEMA SELECT SEQUENCE SET TABLE THEN TO TRIGGER TRUNCATE UNION UNIQUE UPDATE
+		VALUES VIEW WHEN WHERE WITH
+		BIGINT BIGSERIAL BOOLEAN CHAR DATE DECIMAL INT INTEGER NUMERIC SERIAL SMALLINT TEXT
+		TIMESTAMP VARCHAR`) {
+		sqlKeywords[keyword] = true
+	}
+}
+
+// applyKeywordCase 按 keywordCase 转换语句中 SQL 关键字的大小写。
+// 借助 Tokenizer 跳过字符串、注释和 $$ 代码块，标识符中包含关键字的部分(如 user_table)不受影响
+func applyKeywordCase(stmt string, keywordCase KeywordCase) (string, error) {
+	var result strings.Builder
+	tokenizer := NewTokenizer(strings.NewReader(stmt))
+	for {
+		token, err := tokenizer.NextToken()
+		if token.Type == TokenDelimiterCommand {
+			result.WriteString(token.Value)
+		} else if token.Value != "" {
+			result.WriteString(changeKeywordCase(token.Value, keywordCase))
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return result.String(), nil
+			}
+			return "", err
+		}
+	}
+}
+
+// changeKeywordCase 转换 token 开头的关键字，AS/DO 的 token 中会带有后面的 $$ 代码块，只转换关键字部分
+func changeKeywordCase(value string, keywordCase KeywordCase) string {
+	end := 0
+	for end < len(value) {
+		r, size := utf8.DecodeRuneInString(value[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+	word := value[:end]
+	if word == "" || !sqlKeywords[strings.ToUpper(word)] {
+		return value
+	}
+
+	switch keywordCase {
+	case KeywordCaseLower:
+		word = strings.ToLower(word)
+	case KeywordCaseUpper:
+		word = strings.ToUpper(word)
+	}
+
+	// END IF、END CASE 这样的 token 中带有后面的单词
+	rest := value[end:]
+	if strings.EqualFold(word, "END") {
+		if next := strings.TrimLeft(rest, " \t"); next != "" {
+			rest = rest[:len(rest)-len(next)] + changeKeywordCase(next, keywordCase)
+		}
+	}
+	return word + rest
+}
+
+// rewriteSchemas 按 rewrites 替换语句中 schema.name 形式的 schema 前缀，见 Config.SchemaRewrites。
+// 借助 Tokenizer 跳过字符串、注释和 $$ 代码块，加引号的 schema 替换后仍然加引号
+func rewriteSchemas(stmt string, rewrites map[string]string) (string, error) {
+	var tokens []Token
+	tokenizer := NewTokenizer(strings.NewReader(stmt))
+	for {
+		token, err := tokenizer.NextToken()
+		if token.Value != "" {
+			tokens = append(tokens, token)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+	}
+
+	var result strings.Builder
+	for idx := 0; idx < len(tokens); idx++ {
+		if idx+1 < len(tokens) && tokens[idx+1].Value == "." {
+			if schema, ok := rewriteSchema(tokens[idx], rewrites); ok {
+				if schema == "" {
+					// 去掉 schema 时后面的 "." 也一起去掉
+					idx++
+				} else {
+					result.WriteString(schema)
+				}
+				continue
+			}
+		}
+		result.WriteString(tokens[idx].Value)
+	}
+	return result.String(), nil
+}
+
+// rewriteSchema 返回 token 作为 schema 名时替换后的内容，token 不是需要替换的 schema 时返回 false
+func rewriteSchema(token Token, rewrites map[string]string) (string, bool) {
+	if token.Type != TokenText {
+		return "", false
+	}
+	value := token.Value
+	if quote := value[0]; quote == '"' || quote == '`' {
+		if len(value) < 2 || value[len(value)-1] != quote {
+			return "", false
+		}
+		for from, to := range rewrites {
+			if strings.TrimSuffix(from, ".") == value[1:len(value)-1] {
+				if to = strings.TrimSuffix(to, "."); to == "" {
+					return "", true
+				}
+				return string(quote) + to + string(quote), true
+			}
+		}
+		return "", false
+	}
+
+	first, _ := utf8.DecodeRuneInString(value)
+	if !unicode.IsLetter(first) && first != '_' {
+		return "", false
+	}
+	for _, r := range value {
+		if !isWordRune(r) {
+			return "", false
+		}
+	}
+	for from, to := range rewrites {
+		if strings.EqualFold(strings.TrimSuffix(from, "."), value) {
+			return strings.TrimSuffix(to, "."), true
+		}
+	}
+	return "", false
+}
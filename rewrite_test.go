@@ -0,0 +1,179 @@
+package goflyway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "collapse spaces and tabs",
+			input:    "SELECT  a,\t\tb   FROM   t;",
+			expected: "SELECT a, b FROM t;",
+		},
+		{
+			name:     "trim trailing whitespace and keep indentation",
+			input:    "CREATE TABLE t (  \n    id   INT \t\n);",
+			expected: "CREATE TABLE t (\n    id INT\n);",
+		},
+		{
+			name:     "quoted string preserved",
+			input:    "INSERT INTO t VALUES  ('a   b\t c');",
+			expected: "INSERT INTO t VALUES ('a   b\t c');",
+		},
+		{
+			name:     "comments preserved",
+			input:    "SELECT   1; --  keep   this  \n/*  and   this  */  SELECT  2;",
+			expected: "SELECT 1; --  keep   this  \n/*  and   this  */ SELECT 2;",
+		},
+		{
+			name:     "AS without dollar block",
+			input:    "SELECT a  AS    b FROM t;",
+			expected: "SELECT a AS b FROM t;",
+		},
+		{
+			name:     "dollar block preserved",
+			input:    "CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\n    PERFORM  1;   \nEND;\n$$   LANGUAGE plpgsql;",
+			expected: "CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\n    PERFORM  1;   \nEND;\n$$ LANGUAGE plpgsql;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := canonicalizeWhitespace(tt.input)
+			if err != nil {
+				t.Fatalf("canonicalizeWhitespace() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("canonicalizeWhitespace() mismatch:\nExpected:\n%q\n\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConvertFlywayToGoose_CanonicalizeWhitespace(t *testing.T) {
+	input := "SELECT   'a  b'  FROM   t;   \n"
+	result, err := convertFlywayToGoose(strings.NewReader(input), &Config{CanonicalizeWhitespace: true})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if !strings.Contains(result, "SELECT 'a  b' FROM t;\n") {
+		t.Errorf("whitespace not canonicalized:\n%s", result)
+	}
+
+	result, err = convertFlywayToGoose(strings.NewReader(input), &Config{})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if !strings.Contains(result, "SELECT   'a  b'  FROM   t;") {
+		t.Errorf("whitespace changed without option:\n%s", result)
+	}
+}
+
+func TestApplyKeywordCase(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		keywordCase KeywordCase
+		expected    string
+	}{
+		{
+			name:        "lower keywords",
+			input:       "SELECT id, select_count FROM user_table WHERE name = 'SELECT FROM';",
+			keywordCase: KeywordCaseLower,
+			expected:    "select id, select_count from user_table where name = 'SELECT FROM';",
+		},
+		{
+			name:        "upper keywords",
+			input:       "create table \"order\" (id int primary key); -- create table\n",
+			keywordCase: KeywordCaseUpper,
+			expected:    "CREATE TABLE \"order\" (id INT PRIMARY KEY); -- create table\n",
+		},
+		{
+			name:        "dollar block preserved",
+			input:       "CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\n    PERFORM 1;\nEND;\n$$ LANGUAGE plpgsql;",
+			keywordCase: KeywordCaseLower,
+			expected:    "create function f() returns void as $$\nBEGIN\n    PERFORM 1;\nEND;\n$$ language plpgsql;",
+		},
+		{
+			name:        "end if",
+			input:       "if a then set b = 1; end if;",
+			keywordCase: KeywordCaseUpper,
+			expected:    "IF a THEN SET b = 1; END IF;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyKeywordCase(tt.input, tt.keywordCase)
+			if err != nil {
+				t.Fatalf("applyKeywordCase() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("applyKeywordCase() mismatch:\nExpected:\n%q\n\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConvertFlywayToGoose_KeywordCase(t *testing.T) {
+	input := "INSERT INTO t (name) VALUES ('Insert Into');\n"
+	expected := "-- +goose Up\ninsert into t (name) values ('Insert Into');\n"
+
+	result, err := convertFlywayToGoose(strings.NewReader(input), &Config{KeywordCase: KeywordCaseLower})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if !strings.HasPrefix(result, expected) {
+		t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+func TestRewriteSchemas(t *testing.T) {
+	rewrites := map[string]string{"public.": "app.", "dbo": ""}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"替换前缀", "CREATE TABLE public.users (id INT);", "CREATE TABLE app.users (id INT);"},
+		{"不区分大小写", "SELECT * FROM PUBLIC.users;", "SELECT * FROM app.users;"},
+		{"去掉前缀", "INSERT INTO dbo.users VALUES (1);", "INSERT INTO users VALUES (1);"},
+		{"加引号的 schema", `SELECT * FROM "public"."users";`, `SELECT * FROM "app"."users";`},
+		{"字符串不变", "INSERT INTO public.t VALUES ('public.users');", "INSERT INTO app.t VALUES ('public.users');"},
+		{"注释不变", "-- public.users\n/* dbo.users */ SELECT 1;", "-- public.users\n/* dbo.users */ SELECT 1;"},
+		{"$$ 代码块不变", "SELECT $$public.users$$;", "SELECT $$public.users$$;"},
+		{"标识符的一部分不变", "SELECT * FROM my_public.users, public_x.t;", "SELECT * FROM my_public.users, public_x.t;"},
+		{"不是前缀", "SELECT public FROM t;", "SELECT public FROM t;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := rewriteSchemas(tt.input, rewrites)
+			if err != nil {
+				t.Fatalf("rewriteSchemas() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("rewriteSchemas() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertFlywayToGoose_SchemaRewrites(t *testing.T) {
+	input := "CREATE TABLE public.users (note TEXT DEFAULT 'public.x'); -- public.users\n"
+	expected := "-- +goose Up\nCREATE TABLE app.users (note TEXT DEFAULT 'public.x');"
+
+	result, err := convertFlywayToGoose(strings.NewReader(input), &Config{SchemaRewrites: map[string]string{"public": "app"}})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if !strings.HasPrefix(result, expected) || !strings.Contains(result, "-- public.users") {
+		t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
@@ -1,8 +1,15 @@
 package goflyway
 
 import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pressly/goose/v3"
 )
 
 func TestConvertFlywayToGoose(t *testing.T) {
@@ -628,3 +635,561 @@ func TestHasSemicolonAtEnt(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertFlywayToGoose_StripStatements(t *testing.T) {
+	input := `-- header
+SET ROLE app;
+CREATE TABLE users (id INT);
+INSERT INTO users VALUES (1);
+reset   role ;
+`
+	expected := `-- +goose Up
+-- header
+
+CREATE TABLE users (id INT);
+
+INSERT INTO users VALUES (1);
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`
+
+	cfg := &Config{StripStatements: []string{"SET ROLE app;", "RESET ROLE"}}
+	result, err := convertFlywayToGoose(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if result != expected {
+		t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+
+	// 中间的同名语句不会被去掉
+	input = "SET ROLE app;\nSELECT 1;\nSET ROLE app;\nSELECT 2;\nRESET ROLE;"
+	result, err = convertFlywayToGoose(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if strings.Count(result, "SET ROLE app;") != 1 || strings.Contains(result, "RESET ROLE") {
+		t.Errorf("unexpected stripping result:\n%s", result)
+	}
+
+	// 使用函数匹配
+	cfg = &Config{StripStatementFunc: func(stmt string) bool {
+		return strings.Contains(strings.ToUpper(stmt), "ROLE")
+	}}
+	result, err = convertFlywayToGoose(strings.NewReader("SET ROLE app;\nSELECT 1;\nRESET ROLE;"), cfg)
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if strings.Contains(result, "ROLE") {
+		t.Errorf("predicate did not strip statements:\n%s", result)
+	}
+}
+
+func TestConvertFlywayToGoose_UnwrappedStatementKinds(t *testing.T) {
+	input := `CREATE TABLE t (id INT, note TEXT DEFAULT 'a;b') ;
+INSERT INTO t VALUES (1, 'x;y') ;
+CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+    PERFORM 1;
+END;
+$$ LANGUAGE plpgsql;`
+
+	cfg := &Config{UnwrappedStatementKinds: []string{"create  table", "INSERT"}}
+	result, err := convertFlywayToGoose(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	expected := `-- +goose Up
+CREATE TABLE t (id INT, note TEXT DEFAULT 'a;b') ;
+
+INSERT INTO t VALUES (1, 'x;y') ;
+
+
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS void AS $$
+BEGIN
+    PERFORM 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`
+	if result != expected {
+		t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+
+	// 不配置时按原有规则包裹
+	result, err = ConvertFlywayToGoose(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ConvertFlywayToGoose() error = %v", err)
+	}
+	if strings.Count(result, "-- +goose StatementBegin") != 3 {
+		t.Errorf("expected all statements wrapped without allowlist:\n%s", result)
+	}
+}
+
+func TestConvertFlywayToGoose_PsqlPreamble(t *testing.T) {
+	input := "\\set ON_ERROR_STOP on\n\\connect app\nCREATE TABLE t (id INT);\n"
+
+	var logs strings.Builder
+	SetLogger(NewStdLogger(log.New(&logs, "", 0)))
+	defer SetLogger(nil)
+
+	result, err := convertFlywayToGoose(strings.NewReader(input), &Config{StripPsqlMeta: true})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if strings.Contains(result, "\\set") || strings.Contains(result, "\\connect") {
+		t.Errorf("psql meta-commands not stripped:\n%s", result)
+	}
+	if !strings.Contains(result, "CREATE TABLE t (id INT);") {
+		t.Errorf("statement missing:\n%s", result)
+	}
+	if !strings.Contains(logs.String(), `stripped non-SQL preamble line "\\set ON_ERROR_STOP on"`) {
+		t.Errorf("expected strip warning, got logs:\n%s", logs.String())
+	}
+
+	logs.Reset()
+	result, err = convertFlywayToGoose(strings.NewReader(input), &Config{})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	if !strings.Contains(result, "\\set ON_ERROR_STOP on") {
+		t.Errorf("psql meta-command should be kept without StripPsqlMeta:\n%s", result)
+	}
+	if !strings.Contains(logs.String(), `non-SQL preamble line "\\connect app" is not supported by goose`) {
+		t.Errorf("expected passthrough warning, got logs:\n%s", logs.String())
+	}
+}
+
+func TestConvertFlywayToGoose_GooseVersion(t *testing.T) {
+	input, err := os.ReadFile("testdata/golden/goose_format_input.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version string
+		golden  string
+	}{
+		{version: "", golden: "goose_v3.sql"},
+		{version: "v3.26.0", golden: "goose_v3.sql"},
+		{version: "v2", golden: "goose_v2.sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := convertFlywayToGoose(strings.NewReader(string(input)), &Config{GooseVersion: tt.version})
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			expected, err := os.ReadFile("testdata/golden/" + tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(expected) {
+				t.Errorf("output mismatch with %s:\nExpected:\n%s\nGot:\n%s", tt.golden, expected, got)
+			}
+		})
+	}
+
+	_, err = convertFlywayToGoose(strings.NewReader(string(input)), &Config{GooseVersion: "v1"})
+	if err == nil {
+		t.Error("expected error for unsupported goose version")
+	}
+}
+
+func TestConvertFlywayToGoose_GenerateDown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:  "可反转的 DDL",
+			input: "CREATE TABLE t (id INT);\nCREATE INDEX idx_t_id ON t (id);\nALTER TABLE t ADD COLUMN c INT;",
+			expected: `-- +goose Up
+CREATE TABLE t (id INT);
+
+CREATE INDEX idx_t_id ON t (id);
+
+ALTER TABLE t ADD COLUMN c INT;
+
+-- +goose Down
+ALTER TABLE t DROP COLUMN c;
+
+DROP INDEX idx_t_id;
+
+DROP TABLE t;
+`,
+		},
+		{
+			name:  "DML 不可反转",
+			input: "CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1);",
+			expected: `-- +goose Up
+CREATE TABLE t (id INT);
+
+INSERT INTO t VALUES (1);
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertFlywayToGoose(strings.NewReader(tt.input), &Config{GenerateDown: true})
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("output mismatch:\nExpected:\n%q\nGot:\n%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvertFlywayToGoose_WrapTransaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		input    string
+		expected string
+	}{
+		{
+			name:   "postgres",
+			driver: "postgres",
+			input:  "CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1)",
+			expected: `-- +goose Up
+BEGIN;
+CREATE TABLE t (id INT);
+
+INSERT INTO t VALUES (1)
+;
+COMMIT;
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`,
+		},
+		{
+			name:   "mysql",
+			driver: "mysql",
+			input:  "CREATE TABLE t (id INT);",
+			expected: `-- +goose Up
+START TRANSACTION;
+CREATE TABLE t (id INT);
+COMMIT;
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`,
+		},
+		{
+			name:   "NO TRANSACTION",
+			driver: "postgres",
+			input:  "-- +goose NO TRANSACTION\nCREATE INDEX CONCURRENTLY idx ON t (id);",
+			expected: `-- +goose Up
+-- +goose NO TRANSACTION
+CREATE INDEX CONCURRENTLY idx ON t (id);
+
+-- +goose Down
+-- Down migration is not supported in automatic conversion
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{WrapTransaction: true, DBDriver: tt.driver}
+			got, err := convertFlywayToGoose(strings.NewReader(tt.input), cfg)
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("output mismatch:\nExpected:\n%q\nGot:\n%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestConvertFlywayToGoose_MaxStatementBytes 测试未闭合的 dollar-quote 触发语句长度检查
+func TestConvertFlywayToGoose_MaxStatementBytes(t *testing.T) {
+	input := `CREATE TABLE a (id INT);
+
+CREATE FUNCTION f() RETURNS INT AS $$
+BEGIN
+  RETURN 1;
+END;
+$ LANGUAGE plpgsql;
+
+CREATE TABLE b (id INT);
+CREATE TABLE c (id INT);
+CREATE TABLE d (id INT);
+`
+
+	_, err := convertFlywayToGoose(strings.NewReader(input), &Config{MaxStatementBytes: 64})
+	if err == nil {
+		t.Fatal("convertFlywayToGoose() expected error for oversized statement")
+	}
+	for _, want := range []string{"line 3", "exceeds 64 bytes", "CREATE FUNCTION f()"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("convertFlywayToGoose() error = %v, want mention of %q", err, want)
+		}
+	}
+
+	// 默认限制足够大，不影响正常的语句
+	if _, err := convertFlywayToGoose(strings.NewReader("CREATE TABLE a (id INT);\n"), &Config{}); err != nil {
+		t.Errorf("convertFlywayToGoose() error = %v", err)
+	}
+}
+
+// TestConvertFlywayToGoose_FlywayDirectives 测试 Flyway 指令注释按配置去掉或替换
+func TestConvertFlywayToGoose_FlywayDirectives(t *testing.T) {
+	input := `-- flyway:timeout=30
+-- flyway:executeInTransaction=false
+-- 普通注释
+CREATE TABLE a (id INT);
+`
+
+	tests := []struct {
+		name       string
+		directives map[string]string
+		expected   string
+	}{
+		{
+			name: "默认配置",
+			expected: `-- +goose Up
+-- NOTE: flyway timeout=30 is not enforced by goose
+-- flyway:executeInTransaction=false
+-- 普通注释
+CREATE TABLE a (id INT);
+
+-- +goose Down
+`,
+		},
+		{
+			name:       "自定义配置",
+			directives: map[string]string{"timeout": "", "executeintransaction": "-- +goose NO TRANSACTION"},
+			expected: `-- +goose Up
+-- +goose NO TRANSACTION
+-- 普通注释
+CREATE TABLE a (id INT);
+
+-- +goose Down
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertFlywayToGoose(strings.NewReader(input), &Config{FlywayDirectives: tt.directives})
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			if !strings.HasPrefix(result, tt.expected) {
+				t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%s\n\nGot:\n%s", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestRewriteFlywayDirectives_QuotedText 测试字符串和 dollar-quote 中形如指令的内容保持不变
+func TestRewriteFlywayDirectives_QuotedText(t *testing.T) {
+	input := `  -- flyway:timeout=30
+CREATE FUNCTION f() RETURNS INT AS $$
+-- flyway:timeout=10
+BEGIN RETURN 1; END
+$$ LANGUAGE plpgsql;
+INSERT INTO notes VALUES ('
+-- flyway:timeout=5
+');
+SELECT 1; -- flyway:timeout=1
+`
+	expected := `CREATE FUNCTION f() RETURNS INT AS $$
+-- flyway:timeout=10
+BEGIN RETURN 1; END
+$$ LANGUAGE plpgsql;
+INSERT INTO notes VALUES ('
+-- flyway:timeout=5
+');
+SELECT 1; -- flyway:timeout=1
+`
+
+	result := rewriteFlywayDirectives(input, map[string]string{"timeout": ""}, TokenizerOptions{})
+	if result != expected {
+		t.Errorf("rewriteFlywayDirectives() mismatch:\nExpected:\n%s\n\nGot:\n%s", expected, result)
+	}
+}
+
+// TestConvertFlywayToGoose_DropEmptyStatements 测试 DropEmptyStatements 丢弃空语句和只有注释的语句
+func TestConvertFlywayToGoose_DropEmptyStatements(t *testing.T) {
+	input := "-- +goose NO TRANSACTION\nCREATE TABLE a (id INT);;\n\nSELECT 1;\n-- trailing comment\n"
+
+	tests := []struct {
+		name     string
+		drop     bool
+		expected string
+	}{
+		{
+			name: "默认保留",
+			expected: `-- +goose Up
+-- +goose NO TRANSACTION
+CREATE TABLE a (id INT);
+;
+
+
+SELECT 1;
+
+-- trailing comment
+`,
+		},
+		{
+			name: "丢弃空语句",
+			drop: true,
+			expected: `-- +goose Up
+-- +goose NO TRANSACTION
+CREATE TABLE a (id INT);
+
+
+SELECT 1;
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertFlywayToGoose(strings.NewReader(input), &Config{DropEmptyStatements: tt.drop})
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			up, _, _ := strings.Cut(result, "\n-- +goose Down")
+			if up != tt.expected {
+				t.Errorf("convertFlywayToGoose() mismatch:\nExpected:\n%q\n\nGot:\n%q", tt.expected, up)
+			}
+		})
+	}
+}
+
+func TestConvertFlywayToGoose_TargetSchema(t *testing.T) {
+	input := "CREATE TABLE a (id INT);\nCREATE FUNCTION f() RETURNS INT AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\n"
+
+	tests := []struct {
+		name     string
+		driver   string
+		restore  string
+		expected string
+		reset    string
+	}{
+		{
+			name:     "Postgres",
+			driver:   "postgres",
+			expected: "-- +goose Up\nSELECT set_config('goflyway.search_path', current_setting('search_path'), false);\nSET search_path TO app;\nCREATE TABLE a (id INT);\n",
+			reset:    "SELECT set_config('search_path', current_setting('goflyway.search_path'), false);\n",
+		},
+		{
+			name:     "Postgres指定恢复的schema",
+			driver:   "postgres",
+			restore:  "public",
+			expected: "-- +goose Up\nSET search_path TO app;\nCREATE TABLE a (id INT);\n",
+			reset:    "SET search_path TO public;\n",
+		},
+		{
+			name:     "MySQL",
+			driver:   "mysql",
+			restore:  "main",
+			expected: "-- +goose Up\nUSE app;\nCREATE TABLE a (id INT);\n",
+			reset:    "USE main;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertFlywayToGoose(strings.NewReader(input), &Config{DBDriver: tt.driver, TargetSchema: "app", RestoreSchema: tt.restore})
+			if err != nil {
+				t.Fatalf("convertFlywayToGoose() error = %v", err)
+			}
+			if !strings.HasPrefix(result, tt.expected) {
+				t.Errorf("convertFlywayToGoose() mismatch:\nExpected prefix:\n%s\nGot:\n%s", tt.expected, result)
+			}
+			// 切换语句只出现一次，并且不在 StatementBegin 块中
+			block := result[strings.Index(result, "-- +goose StatementBegin"):]
+			if strings.Count(result, "app;") != 1 || strings.Contains(block, "app;") {
+				t.Errorf("schema statement should appear once before any block:\n%s", result)
+			}
+			// Up 部分结尾切换回原来的 schema，Down 部分不变
+			up, down, _ := strings.Cut(result, "-- +goose Down")
+			if !strings.HasSuffix(strings.TrimRight(up, "\n"), strings.TrimRight(tt.reset, "\n")) {
+				t.Errorf("Up should end with %q:\n%s", tt.reset, result)
+			}
+			if strings.Contains(down, "app;") || strings.Contains(down, tt.reset) {
+				t.Errorf("schema statements should not be added to Down:\n%s", result)
+			}
+		})
+	}
+
+	if _, err := convertFlywayToGoose(strings.NewReader(input), &Config{DBDriver: "sqlite3", TargetSchema: "app"}); err == nil {
+		t.Error("convertFlywayToGoose() expected error for sqlite3")
+	}
+	if _, err := convertFlywayToGoose(strings.NewReader(input), &Config{DBDriver: "postgres", TargetSchema: "app; DROP TABLE a"}); err == nil {
+		t.Error("convertFlywayToGoose() expected error for invalid schema")
+	}
+	if _, err := convertFlywayToGoose(strings.NewReader(input), &Config{DBDriver: "mysql", TargetSchema: "app"}); err == nil {
+		t.Error("convertFlywayToGoose() expected error for mysql without a restore schema")
+	}
+}
+
+// TestTargetSchema_GooseVersionRow 测试 goose 在切换回原来的 schema 之后才写入版本记录
+func TestTargetSchema_GooseVersionRow(t *testing.T) {
+	content, err := convertFlywayToGoose(strings.NewReader("CREATE TABLE a (id INT);\n"), &Config{DBDriver: "postgres", TargetSchema: "app"})
+	if err != nil {
+		t.Fatalf("convertFlywayToGoose() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "00001_init.sql")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config('goflyway.search_path', current_setting('search_path'), false);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET search_path TO app;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE a (id INT);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT set_config('search_path', current_setting('goflyway.search_path'), false);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2)").WithArgs(int64(1), true).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+	migration := &goose.Migration{Version: 1, Source: path}
+	if err := migration.Up(db); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConvertString(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);\n",
+		"CREATE FUNCTION f() RETURNS INT AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\n",
+	} {
+		expected, expectedErr := ConvertFlywayToGoose(strings.NewReader(input))
+		result, err := ConvertString(input)
+		if result != expected || !reflect.DeepEqual(err, expectedErr) {
+			t.Errorf("ConvertString(%q) = %q, %v, want %q, %v", input, result, err, expected, expectedErr)
+		}
+	}
+}
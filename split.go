@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenType 表示解析出的 token 类型
@@ -17,6 +18,7 @@ const (
 	TokenBegin
 	TokenEnd
 	TokenDelimiterCommand
+	TokenComment // 普通的行注释或块注释
 )
 
 // Token 表示解析出的词法单元
@@ -26,23 +28,58 @@ type Token struct {
 	DelimiterWord string // 当前使用的分隔符
 }
 
+// TokenizerOptions 为 Tokenizer 处理注释的方式
+type TokenizerOptions struct {
+	// CommentTokens 为 true 时普通的行注释和块注释返回 TokenComment，否则与以前一样返回 TokenText
+	CommentTokens bool
+
+	// ExecutableComments 为 true 时(需要同时设置 CommentTokens)将 MySQL 的优化器提示(/*+ ... */)
+	// 和版本条件注释(/*!40101 ... */)作为 TokenText 返回，它们会被数据库执行，不能当作普通注释
+	ExecutableComments bool
+
+	// NestedComments 为 true 时支持 /* /* */ */ 这样嵌套的块注释(PostgreSQL、SQL Server 等)，
+	// 否则块注释在第一个 */ 处结束
+	NestedComments bool
+}
+
+// tokenizerOptions 返回按 cfg 分割语句时使用的 TokenizerOptions
+func (cfg *Config) tokenizerOptions() TokenizerOptions {
+	return TokenizerOptions{NestedComments: cfg.NestedComments}
+}
+
 // Tokenizer 封装 SQL 解析器
 type Tokenizer struct {
 	reader *bufio.Reader
+	opts   TokenizerOptions
+	last   rune // 上一个 token 的最后一个字符，用于区分 dollar-quote 和标识符中的 $
 }
 
+// NewTokenizer 创建一个注释作为 TokenText 返回、不支持嵌套块注释的 Tokenizer
 func NewTokenizer(in io.Reader) *Tokenizer {
-	return &Tokenizer{reader: bufio.NewReader(in)}
+	return NewTokenizerWithOptions(in, TokenizerOptions{})
+}
+
+// NewTokenizerWithOptions 创建一个按 opts 处理注释的 Tokenizer
+func NewTokenizerWithOptions(in io.Reader, opts TokenizerOptions) *Tokenizer {
+	return &Tokenizer{reader: bufio.NewReader(in), opts: opts}
 }
 
 func (t *Tokenizer) NextToken() (Token, error) {
+	token, err := t.nextToken()
+	if token.Value != "" {
+		t.last, _ = utf8.DecodeLastRuneInString(token.Value)
+	}
+	return token, err
+}
+
+func (t *Tokenizer) nextToken() (Token, error) {
 	r, err := t.readRune()
 	if err != nil {
 		return Token{}, err // 返回错误（包括io.EOF）
 	}
 
 	switch {
-	case r == '\'' || r == '"':
+	case r == '\'' || r == '"' || r == '`':
 		return t.readQuotedString(r)
 
 	case r == '-':
@@ -64,6 +101,13 @@ func (t *Tokenizer) NextToken() (Token, error) {
 	case r == ';':
 		return Token{Type: TokenSemicolon, Value: ";"}, nil
 
+	case r == '$' && !isWordRune(t.last):
+		// $$ 或 $tag$ 可以出现在任何位置(如 SELECT $$a;b$$)，标识符中的 $ 和 $1 这样的参数除外
+		if tag, ok := t.peekDollarTag(); ok {
+			return t.readDollarQuoted(tag)
+		}
+		return Token{Type: TokenText, Value: string(r)}, nil
+
 	case unicode.IsLetter(r) || r == '_':
 		return t.readWord(r)
 
@@ -117,7 +161,7 @@ func (t *Tokenizer) readLineComment() (Token, error) {
 		if errors.Is(err, io.EOF) {
 			err = nil
 		}
-		return Token{Type: TokenText, Value: builder.String()}, err
+		return Token{Type: t.commentType(), Value: builder.String()}, err
 	}
 	builder.WriteRune(second)
 
@@ -127,7 +171,7 @@ func (t *Tokenizer) readLineComment() (Token, error) {
 			if errors.Is(err, io.EOF) {
 				err = nil
 			}
-			return Token{Type: TokenText, Value: builder.String()}, err
+			return Token{Type: t.commentType(), Value: builder.String()}, err
 		}
 		builder.WriteRune(r)
 		if r == '\n' {
@@ -135,7 +179,15 @@ func (t *Tokenizer) readLineComment() (Token, error) {
 		}
 	}
 
-	return Token{Type: TokenText, Value: builder.String()}, nil
+	return Token{Type: t.commentType(), Value: builder.String()}, nil
+}
+
+// commentType 返回普通注释的 token 类型
+func (t *Tokenizer) commentType() TokenType {
+	if t.opts.CommentTokens {
+		return TokenComment
+	}
+	return TokenText
 }
 
 func (t *Tokenizer) readBlockComment() (Token, error) {
@@ -144,39 +196,92 @@ func (t *Tokenizer) readBlockComment() (Token, error) {
 
 	second, err := t.readRune()
 	if err != nil {
-		return Token{Type: TokenText, Value: builder.String()}, err
+		return Token{Type: t.commentType(), Value: builder.String()}, err
 	}
 	builder.WriteRune(second)
 
-	for {
+	tokenType := t.commentType()
+	if t.opts.ExecutableComments {
+		if next, err := t.peekRune(); err == nil && (next == '!' || next == '+') {
+			tokenType = TokenText
+		}
+	}
+
+	depth := 1
+	for depth > 0 {
 		r, err := t.readRune()
 		if err != nil {
-			return Token{Type: TokenText, Value: builder.String()}, err
+			return Token{Type: tokenType, Value: builder.String()}, err
 		}
 
 		builder.WriteRune(r)
 
-		if r == '*' {
-			if next, err := t.peekRune(); err != nil {
-				if err != io.EOF {
-					return Token{Type: TokenText, Value: builder.String()}, err
-				}
-				break
-			} else if next == '/' {
-				end, err := t.readRune()
-				if err != nil {
-					if err != io.EOF {
-						return Token{Type: TokenText, Value: builder.String()}, err
-					}
-					break
-				}
-				builder.WriteRune(end)
-				break
+		var closing bool
+		switch {
+		case r == '*':
+			closing = true
+		case r == '/' && t.opts.NestedComments:
+		default:
+			continue
+		}
+
+		next, err := t.peekRune()
+		if err != nil {
+			if err != io.EOF {
+				return Token{Type: tokenType, Value: builder.String()}, err
 			}
+			break
+		}
+		if closing && next == '/' {
+			depth--
+		} else if !closing && next == '*' {
+			depth++
+		} else {
+			continue
 		}
+		second, err := t.readRune()
+		if err != nil {
+			if err != io.EOF {
+				return Token{Type: tokenType, Value: builder.String()}, err
+			}
+			break
+		}
+		builder.WriteRune(second)
 	}
 
-	return Token{Type: TokenText, Value: builder.String()}, nil
+	return Token{Type: tokenType, Value: builder.String()}, nil
+}
+
+// peekDollarTag 在读到 $ 之后检查其后是否为 dollar-quote 的开始标记 tag$，不消耗输入
+func (t *Tokenizer) peekDollarTag() (string, bool) {
+	for n := 1; ; n++ {
+		buf, err := t.reader.Peek(n)
+		if err != nil {
+			return "", false
+		}
+		c := buf[n-1]
+		switch {
+		case c == '$':
+			return string(buf[:n-1]), true
+		case c == '_' || c >= utf8.RuneSelf || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z'):
+		case n > 1 && '0' <= c && c <= '9':
+		default:
+			return "", false
+		}
+	}
+}
+
+// readDollarQuoted 读取 $tag$ ... $tag$ 形式的字符串，开头的 $ 已经被读取
+func (t *Tokenizer) readDollarQuoted(tag string) (Token, error) {
+	if _, err := t.reader.Discard(len(tag) + 1); err != nil {
+		return Token{Type: TokenText, Value: "$"}, err
+	}
+	content, err := t.readUntilBlockDelimiter(tag)
+	value := "$" + tag + "$" + content
+	if err != nil && err != io.EOF {
+		return Token{Type: TokenText, Value: value}, err
+	}
+	return Token{Type: TokenText, Value: value}, nil
 }
 
 // 读取块分隔符内的内容
@@ -253,10 +358,11 @@ func (t *Tokenizer) readWord(first rune) (Token, error) {
 	upperWord := strings.ToUpper(word)
 
 	switch upperWord {
-	case "BEGIN":
+	case "BEGIN", "CASE":
+		// CASE 表达式和 CASE 语句都以 END(或 END CASE)结束，与 BEGIN 一样计入块深度
 		return Token{Type: TokenBegin, Value: word}, nil
 	case "END":
-		return Token{Type: TokenEnd, Value: word}, nil
+		return t.readEnd(word)
 	case "DELIMITER":
 		return processDelimiterCommand(t.reader, word)
 	case "AS":
@@ -268,6 +374,63 @@ func (t *Tokenizer) readWord(first rune) (Token, error) {
 	}
 }
 
+// endTerminators 为 END 之后表示结束 IF/LOOP 等结构(而不是 BEGIN 或 CASE 块)的单词
+var endTerminators = map[string]bool{
+	"IF":     true,
+	"LOOP":   true,
+	"WHILE":  true,
+	"REPEAT": true,
+}
+
+// readEnd 处理 END 之后同一行中的 IF/LOOP/WHILE/REPEAT/CASE。
+// IF/LOOP 等不计入块深度，因此 END IF 这样的结束符不减少深度；END CASE 与 END 一样结束 CASE
+func (t *Tokenizer) readEnd(word string) (Token, error) {
+	next, n := t.peekWordInLine()
+	upper := strings.ToUpper(next)
+	if upper != "CASE" && !endTerminators[upper] {
+		return Token{Type: TokenEnd, Value: word}, nil
+	}
+
+	buf, err := t.reader.Peek(n)
+	if err != nil {
+		return Token{Type: TokenEnd, Value: word}, err
+	}
+	value := word + string(buf)
+	if _, err := t.reader.Discard(n); err != nil {
+		return Token{Type: TokenEnd, Value: word}, err
+	}
+	if upper == "CASE" {
+		return Token{Type: TokenEnd, Value: value}, nil
+	}
+	return Token{Type: TokenText, Value: value}, nil
+}
+
+// peekWordInLine 返回跳过空格和制表符后的下一个单词，以及到单词结尾的字节数，不消耗输入
+func (t *Tokenizer) peekWordInLine() (string, int) {
+	start := -1
+	for n := 1; ; n++ {
+		buf, err := t.reader.Peek(n)
+		if err != nil {
+			if start < 0 {
+				return "", 0
+			}
+			return string(buf[start:]), len(buf)
+		}
+		c := buf[n-1]
+		isLetter := ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+		switch {
+		case start < 0 && (c == ' ' || c == '\t'):
+		case start < 0 && isLetter:
+			start = n - 1
+		case start >= 0 && (isLetter || c == '_' || ('0' <= c && c <= '9')):
+		case start < 0:
+			return "", 0
+		default:
+			return string(buf[start : n-1]), n - 1
+		}
+	}
+}
+
 // 处理 AS 后的块分隔符开始
 func (t *Tokenizer) processCodeBlockStart(word string) (Token, error) {
 	var result strings.Builder
@@ -307,81 +470,31 @@ func (t *Tokenizer) processCodeBlockStart(word string) (Token, error) {
 
 	if peek == '$' {
 		// 消耗美元符号
-		dollar, err := t.readRune()
-		if err != nil {
-			if err != io.EOF {
-				return Token{Type: TokenText, Value: result.String()}, err
-			}
-			result.WriteRune('$')
-			return Token{Type: TokenText, Value: result.String()}, nil
-		}
-		result.WriteRune(dollar)
-
-		// 读取标签
-		tag, err := t.readUntilRune('$')
-		if err != nil {
-			if err != io.EOF {
-				return Token{Type: TokenText, Value: result.String() + tag}, err
-			}
-			result.WriteString(tag)
-			return Token{Type: TokenText, Value: result.String()}, nil
-		}
-
-		// 添加标签到结果
-		result.WriteString(tag)
-
-		// 消耗结束美元符号
-		endDollar, err := t.readRune()
-		if err != nil {
+		if _, err := t.readRune(); err != nil {
 			if err != io.EOF {
 				return Token{Type: TokenText, Value: result.String()}, err
 			}
-			return Token{Type: TokenText, Value: result.String()}, nil
+			return Token{Type: TokenText, Value: result.String() + "$"}, nil
 		}
-		result.WriteRune(endDollar)
+		result.WriteRune('$')
 
-		// 读取整个块内容
-		blockContent, err := t.readUntilBlockDelimiter(tag)
-		if err != nil {
-			if err != io.EOF {
-				return Token{Type: TokenText, Value: result.String()}, err
-			}
-			result.WriteString(blockContent)
+		// 只接受合法的标记，否则 AS $1 这样的内容会一直读到下一个 $ 为止
+		tag, ok := t.peekDollarTag()
+		if !ok {
 			return Token{Type: TokenText, Value: result.String()}, nil
 		}
 
-		// 添加块内容到结果
-		result.WriteString(blockContent)
-
-		return Token{
-			Type:  TokenText,
-			Value: result.String(),
-		}, nil
+		// 整个块读到相同标记的结束符为止，块中其它标记的 dollar-quote(如 $func$ 中的 $body$ ... $body$)
+		// 和 $$ 都作为块的内容原样保留
+		block, err := t.readDollarQuoted(tag)
+		result.WriteString(strings.TrimPrefix(block.Value, "$"))
+		return Token{Type: TokenText, Value: result.String()}, err
 	}
 
 	// 没有找到块分隔符，返回普通文本
 	return Token{Type: TokenText, Value: result.String()}, nil
 }
 
-// 读取直到指定字符（包含在值中），但将分隔符放回输入流
-func (t *Tokenizer) readUntilRune(delim rune) (string, error) {
-	var builder strings.Builder
-	for {
-		r, err := t.readRune()
-		if err != nil {
-			return builder.String(), err
-		}
-		if r == delim {
-			// 将分隔符放回输入流
-			if err := t.reader.UnreadRune(); err != nil {
-				return builder.String(), err
-			}
-			return builder.String(), nil
-		}
-		builder.WriteRune(r)
-	}
-}
-
 func processDelimiterCommand(in *bufio.Reader, commandStart string) (Token, error) {
 	var builder strings.Builder
 
@@ -472,38 +585,127 @@ func readUntilDelimiter(reader *bufio.Reader, delim string) (string, string, err
 	}
 }
 
+// SplitString 与 Split 相同，输入为字符串
+func SplitString(sql string) ([]string, error) {
+	return Split(strings.NewReader(sql))
+}
+
 // Split 分割 SQL 语句
 func Split(in io.Reader) ([]string, error) {
-	blocks, tokens := splitByDelimiter(in)
+	statements, _, err := SplitAnnotated(in)
+	return statements, err
+}
+
+// SplitWithConfig 与 Split 相同，按 cfg 中的选项(如 NestedComments)识别注释
+func SplitWithConfig(in io.Reader, cfg *Config) ([]string, error) {
 	var statements []string
+	err := splitAnnotatedFunc(in, cfg.tokenizerOptions(), cfg.logger(), func(stmt string, _ bool, _ string) bool {
+		statements = append(statements, stmt)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
 
-	for idx, block := range blocks {
-		if tokens[idx] {
-			statements = append(statements, block)
-			continue
-		}
-		if isEmptyOrComments(block) {
-			statements = append(statements, block)
-			continue
-		}
+// Statement 是 SplitDetailed 返回的一条语句
+type Statement struct {
+	Text      string // 语句的原始内容
+	Annotated bool   // 是否为 "-- +goose StatementBegin/StatementEnd" 包围的块
+	LineCount int    // 去掉前后空白后语句占的行数
+	ByteSize  int    // 语句的字节数
+	Delimiter string // 语句使用的分隔符，默认为 ";"，在 DELIMITER 命令之后为该命令指定的分隔符
+}
 
-		lines, err := splitBlock(strings.NewReader(block))
-		if err != nil {
-			return nil, err
-		}
+// SplitDetailed 与 Split 相同，并返回每条语句的行数和字节数等信息，用于找出文件中特别大的语句
+func SplitDetailed(in io.Reader) ([]Statement, error) {
+	return splitDetailed(in, TokenizerOptions{}, getLogger())
+}
 
-		if len(lines) > 0 {
-			statements = append(statements, lines...)
-		}
+// splitDetailed 是 SplitDetailed 的实现，按 opts 识别注释，警告输出到 log
+func splitDetailed(in io.Reader, opts TokenizerOptions, log Logger) ([]Statement, error) {
+	var statements []Statement
+	err := splitAnnotatedFunc(in, opts, log, func(text string, annotated bool, delim string) bool {
+		stmt := Statement{
+			Text:      text,
+			Annotated: annotated,
+			ByteSize:  len(text),
+			Delimiter: delim,
+		}
+		if trimmed := strings.TrimSpace(text); trimmed != "" {
+			stmt.LineCount = strings.Count(trimmed, "\n") + 1
+		}
+		statements = append(statements, stmt)
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return statements, nil
 }
 
-// Split 分割 SQL 语句
+// SplitAnnotated 与 Split 相同，并返回每条语句是否为 "-- +goose StatementBegin/StatementEnd" 包围的块。
+// 这样的块(包括注解行)作为一条完整的语句返回，其中的分号不会再被分割
+func SplitAnnotated(in io.Reader) ([]string, []bool, error) {
+	var statements []string
+	var annotated []bool
+	err := splitAnnotatedFunc(in, TokenizerOptions{}, getLogger(), func(stmt string, isAnnotated bool, _ string) bool {
+		statements = append(statements, stmt)
+		annotated = append(annotated, isAnnotated)
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return statements, annotated, nil
+}
+
+// SplitIter 与 Split 相同，但每分割出一条语句就调用 yield，不需要先得到全部语句；
+// yield 返回 false 时停止分割并返回 nil
+func SplitIter(in io.Reader, yield func(stmt string) bool) error {
+	return splitAnnotatedFunc(in, TokenizerOptions{}, getLogger(), func(stmt string, _ bool, _ string) bool {
+		return yield(stmt)
+	})
+}
+
+// errStopSplit 表示 yield 要求停止分割
+var errStopSplit = errors.New("stop split")
+
+// splitAnnotatedFunc 是 SplitAnnotated、SplitDetailed 和 SplitIter 的实现，delim 为语句使用的分隔符，
+// opts 为分割时 Tokenizer 识别注释的方式，警告输出到 log
+func splitAnnotatedFunc(in io.Reader, opts TokenizerOptions, log Logger, yield func(stmt string, annotated bool, delim string) bool) error {
+	err := splitByDelimiterFunc(in, "goose", log, func(block string, isAnnotated bool) error {
+		if isAnnotated || isEmptyOrComments(block) {
+			if !yield(block, isAnnotated, ";") {
+				return errStopSplit
+			}
+			return nil
+		}
+		return splitBlockFunc(strings.NewReader(block), opts, func(stmt, delim string) bool {
+			return yield(stmt, false, delim)
+		})
+	})
+	if err == errStopSplit {
+		return nil
+	}
+	return err
+}
+
+// splitBlock 分割 SQL 语句
 func splitBlock(in io.Reader) ([]string, error) {
 	var statements []string
-	tokenizer := NewTokenizer(in)
+	err := splitBlockFunc(in, TokenizerOptions{}, func(stmt, _ string) bool {
+		statements = append(statements, stmt)
+		return true
+	})
+	return statements, err
+}
+
+// splitBlockFunc 与 splitBlock 相同，每分割出一条语句就调用 yield，delim 为该语句使用的分隔符，
+// yield 返回 false 时停止并返回 errStopSplit
+func splitBlockFunc(in io.Reader, opts TokenizerOptions, yield func(stmt, delim string) bool) error {
+	tokenizer := NewTokenizerWithOptions(in, opts)
 	var stmtBuilder strings.Builder
 	beginDepth := 0
 	currentDelim := ";"
@@ -518,18 +720,22 @@ func splitBlock(in io.Reader) ([]string, error) {
 						stmtBuilder.WriteString(content)
 					}
 					if stmtBuilder.Len() > 0 {
-						statements = append(statements, stmtBuilder.String())
+						if !yield(stmtBuilder.String(), currentDelim) {
+							return errStopSplit
+						}
 						stmtBuilder.Reset()
 					}
 					break
 				}
-				return nil, err
+				return err
 			}
 
 			stmtBuilder.WriteString(content)
 
 			if s := stmtBuilder.String(); strings.TrimSpace(s) != "" {
-				statements = append(statements, stmtBuilder.String())
+				if !yield(stmtBuilder.String(), currentDelim) {
+					return errStopSplit
+				}
 			}
 			stmtBuilder.Reset()
 
@@ -543,7 +749,7 @@ func splitBlock(in io.Reader) ([]string, error) {
 			if err == io.EOF {
 				break // 正常结束
 			}
-			return nil, err
+			return err
 		}
 
 		switch token.Type {
@@ -555,7 +761,9 @@ func splitBlock(in io.Reader) ([]string, error) {
 
 				// 添加完整的语句
 				if stmtBuilder.Len() > 0 {
-					statements = append(statements, stmtBuilder.String())
+					if !yield(stmtBuilder.String(), currentDelim) {
+						return errStopSplit
+					}
 					stmtBuilder.Reset()
 				}
 			} else {
@@ -578,7 +786,9 @@ func splitBlock(in io.Reader) ([]string, error) {
 			if stmtBuilder.Len() > 0 {
 				s := strings.TrimSpace(stmtBuilder.String())
 				if s != "" {
-					statements = append(statements, stmtBuilder.String())
+					if !yield(stmtBuilder.String(), currentDelim) {
+						return errStopSplit
+					}
 				}
 			}
 			currentDelim = token.DelimiterWord
@@ -586,7 +796,7 @@ func splitBlock(in io.Reader) ([]string, error) {
 
 			// fmt.Println("=======TokenDelimiterCommand", token.DelimiterWord)
 
-		case TokenText:
+		case TokenText, TokenComment:
 			// fmt.Println("=======TokenText", token.Value)
 			stmtBuilder.WriteString(token.Value)
 		}
@@ -594,10 +804,12 @@ func splitBlock(in io.Reader) ([]string, error) {
 
 	// 添加最后一条语句（如果存在）
 	if stmtBuilder.Len() > 0 && strings.TrimSpace(stmtBuilder.String()) != "" {
-		statements = append(statements, stmtBuilder.String())
+		if !yield(stmtBuilder.String(), currentDelim) {
+			return errStopSplit
+		}
 	}
 
-	return statements, nil
+	return nil
 }
 
 func isWordRune(r rune) bool {
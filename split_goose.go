@@ -2,8 +2,8 @@ package goflyway
 
 import (
 	"bufio"
+	"fmt"
 	"io"
-	"log"
 	"strings"
 )
 
@@ -16,48 +16,54 @@ import (
 // within a statement. For these cases, we provide the explicit annotations
 // 'StatementBegin' and 'StatementEnd' to allow the script to
 // tell us to ignore semicolons.
-func splitByDelimiter(r io.Reader) (stmts []string, tokens []bool) {
+func splitByDelimiter(r io.Reader) (stmts []string, tokens []bool, err error) {
 	return SplitByDelimiter(r, "goose")
 }
 
-func SplitByDelimiter(r io.Reader, prefix string) ([]string, []bool) {
+// SplitByDelimiter 按 "-- +<prefix> StatementBegin/StatementEnd" 注解将 r 分成块，
+// 返回的 bool 表示对应的块是否为 StatementBegin/StatementEnd 包围的块，读取失败时返回错误
+func SplitByDelimiter(r io.Reader, prefix string) ([]string, []bool, error) {
+	var stmts []string
+	var blocks []bool
+	err := splitByDelimiterFunc(r, prefix, getLogger(), func(block string, annotated bool) error {
+		stmts = append(stmts, block)
+		blocks = append(blocks, annotated)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmts, blocks, nil
+}
+
+// splitByDelimiterFunc 与 SplitByDelimiter 相同，每得到一个块就调用 emit，emit 返回错误时停止并返回该错误，
+// 警告(如 StatementBegin 没有对应的 StatementEnd)输出到 log
+func splitByDelimiterFunc(r io.Reader, prefix string, log Logger, emit func(block string, annotated bool) error) error {
 	var buf strings.Builder
-	scanner := bufio.NewScanner(r)
-	maxSize := 8 * 1024 * 1024
-	scanner.Buffer(make([]byte, maxSize), maxSize)
+	// 使用 bufio.Reader 而不是 bufio.Scanner，单行的长度(如很长的 INSERT 或 base64 数据)没有限制
+	reader := bufio.NewReader(r)
 
 	isFirst := true
 	inStatementBlock := false
-	var stmts []string
-	var blocks []bool
 
-	for scanner.Scan() {
-		text := scanner.Text()
-
-		if line := strings.TrimSpace(text); strings.HasPrefix(line, "--") {
-			ss := strings.Fields(line)
-			var cmd string
-			if prefix == "" {
-				if len(ss) == 2 {
-					// -- +StatementBegin
-					cmd = strings.TrimPrefix(ss[1], "+")
-				}
-			} else {
-				if len(ss) == 3 && (ss[1] == prefix || ss[1] == "+"+prefix) {
-					// -- +goose StatementBegin
-					cmd = ss[2]
-				} else if len(ss) == 2 {
-					// -- +StatementBegin
-					cmd = strings.TrimPrefix(ss[1], "+")
-				}
-			}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("scanning migration: %w", err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
 
+		if cmd := annotationCommand(text, prefix); cmd != "" {
 			// handle any goose-specific commands
 			if cmd == "StatementBegin" || cmd == "statementBegin" {
 				s := strings.TrimSpace(buf.String())
 				if s != "" {
-					stmts = append(stmts, buf.String())
-					blocks = append(blocks, false)
+					if err := emit(buf.String(), false); err != nil {
+						return err
+					}
 				}
 
 				buf.Reset()
@@ -74,8 +80,9 @@ func SplitByDelimiter(r io.Reader, prefix string) ([]string, []bool) {
 
 				buf.WriteString(text)
 
-				stmts = append(stmts, buf.String())
-				blocks = append(blocks, true)
+				if err := emit(buf.String(), true); err != nil {
+					return err
+				}
 				buf.Reset()
 
 				isFirst = true
@@ -90,29 +97,87 @@ func SplitByDelimiter(r io.Reader, prefix string) ([]string, []bool) {
 			buf.WriteString("\n")
 		}
 
-		if _, err := buf.WriteString(text); err != nil {
-			log.Fatalf("io err: %v", err)
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("scanning migration: %v", err)
+		buf.WriteString(text)
 	}
 
 	if buf.Len() > 0 {
 		stmt := strings.TrimSpace(buf.String())
 		if stmt != "" {
-			stmts = append(stmts, buf.String())
-			blocks = append(blocks, false)
+			if err := emit(buf.String(), false); err != nil {
+				return err
+			}
 		}
 	}
 
 	// diagnose likely migration script errors
 	if inStatementBlock {
-		log.Println("WARNING: saw '-- +gobatis StatementBegin' with no matching '-- +gobatis StatementEnd'")
+		log.Warnf("saw '-- +%s StatementBegin' with no matching '-- +%s StatementEnd'", prefix, prefix)
 	}
+	return nil
+}
 
-	return stmts, blocks
+// annotationCommand 返回 "-- +<prefix> StatementBegin" 或 "-- +StatementBegin" 这样的注解行中的命令，
+// 不是注解行时返回空字符串
+func annotationCommand(line, prefix string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "--") {
+		return ""
+	}
+	ss := strings.Fields(line)
+	if prefix != "" && len(ss) == 3 && (ss[1] == prefix || ss[1] == "+"+prefix) {
+		// -- +goose StatementBegin
+		return ss[2]
+	}
+	if len(ss) == 2 {
+		// -- +StatementBegin
+		return strings.TrimPrefix(ss[1], "+")
+	}
+	return ""
+}
+
+// ValidateGooseSQL 检查转换后的内容能否被 goose 正确解析：能按 SplitByDelimiter 分割，
+// 有 -- +goose Up，StatementBegin 和 StatementEnd 成对出现且不跨越 Up/Down，
+// 并且按 goose 的规则解析 Up 和 Down 部分都不出错(如最后一条语句缺少分号、重复的 Up、未知的 +goose 注解)
+func ValidateGooseSQL(content string) error {
+	// StatementBegin 没有对应的 StatementEnd 时下面返回错误，不再输出警告
+	err := splitByDelimiterFunc(strings.NewReader(content), "goose", nopLogger{}, func(string, bool) error { return nil })
+	if err != nil {
+		return err
+	}
+
+	hasUp := false
+	beginLine := 0 // 未结束的 StatementBegin 所在的行
+	for idx, line := range strings.Split(content, "\n") {
+		switch cmd := annotationCommand(line, "goose"); cmd {
+		case "Up", "Down":
+			if beginLine > 0 {
+				return fmt.Errorf("line %d: StatementBegin at line %d is not closed before %s", idx+1, beginLine, cmd)
+			}
+			hasUp = hasUp || cmd == "Up"
+		case "StatementBegin", "statementBegin":
+			if beginLine > 0 {
+				return fmt.Errorf("line %d: nested StatementBegin, the one at line %d is not closed", idx+1, beginLine)
+			}
+			beginLine = idx + 1
+		case "StatementEnd", "statementEnd":
+			if beginLine == 0 {
+				return fmt.Errorf("line %d: StatementEnd without StatementBegin", idx+1)
+			}
+			beginLine = 0
+		}
+	}
+	if beginLine > 0 {
+		return fmt.Errorf("line %d: StatementBegin has no matching StatementEnd", beginLine)
+	}
+	if !hasUp {
+		return fmt.Errorf("missing -- +goose Up annotation")
+	}
+	for _, up := range []bool{true, false} {
+		if _, err := parseGooseStatements(content, up); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func isEmptyOrComments(block string) bool {
@@ -134,3 +199,110 @@ func isEmptyOrComments(block string) bool {
 	}
 	return true
 }
+
+// parseGooseStatements 按 goose 执行迁移时的规则解析 content，返回 Up(up 为 true)或 Down 部分的语句：
+// StatementBegin/StatementEnd 之间为一条语句，其它语句在行尾(-- 注释之前)的分号处结束，
+// 语句之前的空行和注释被忽略。与 goose 的解析器一致，但不展开 ENVSUB 的环境变量
+func parseGooseStatements(content string, up bool) ([]string, error) {
+	const (
+		start = iota
+		inUp
+		inDown
+	)
+	state, inBlock := start, false
+
+	var stmts []string
+	var buf strings.Builder
+	flush := func() {
+		stmts = append(stmts, strings.TrimSpace(buf.String()))
+		buf.Reset()
+	}
+
+	reader := bufio.NewReader(strings.NewReader(content))
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err == io.EOF {
+			break
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if state == start && strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "--") && strings.Contains(line, "+goose") {
+			cmd := strings.TrimSpace(strings.Replace(strings.ReplaceAll(line, "--", ""), "+goose", "", 1))
+			switch {
+			case strings.EqualFold(cmd, "Up"):
+				if state != start {
+					return nil, fmt.Errorf("duplicate '-- +goose Up' annotations")
+				}
+				state = inUp
+			case strings.EqualFold(cmd, "Down"):
+				if state != inUp || inBlock {
+					return nil, fmt.Errorf("must start with '-- +goose Up' annotation")
+				}
+				if remaining := strings.TrimSpace(buf.String()); remaining != "" {
+					return nil, fmt.Errorf("unexpected unfinished SQL query: %q: missing semicolon?", remaining)
+				}
+				state = inDown
+			case strings.EqualFold(cmd, "StatementBegin"):
+				if state == start || inBlock {
+					return nil, fmt.Errorf("'-- +goose StatementBegin' must be defined after '-- +goose Up' or '-- +goose Down' annotation")
+				}
+				inBlock = true
+			case strings.EqualFold(cmd, "StatementEnd"):
+				if !inBlock {
+					return nil, fmt.Errorf("'-- +goose StatementEnd' must be defined after '-- +goose StatementBegin'")
+				}
+				inBlock = false
+				if (state == inUp) == up {
+					flush()
+				}
+				buf.Reset()
+			case strings.EqualFold(cmd, "NO TRANSACTION"), strings.EqualFold(cmd, "ENVSUB ON"), strings.EqualFold(cmd, "ENVSUB OFF"):
+			default:
+				return nil, fmt.Errorf("unknown annotation: %q", cmd)
+			}
+			continue
+		}
+
+		// 语句开始之前的注释和空行被忽略
+		if buf.Len() == 0 && (strings.HasPrefix(strings.TrimSpace(line), "--") || line == "") {
+			continue
+		}
+		if state == start {
+			return nil, fmt.Errorf("failed to parse migration: unexpected line %q before '-- +goose Up' annotation", line)
+		}
+		buf.WriteString(line + "\n")
+		if (state == inUp) != up {
+			buf.Reset()
+			continue
+		}
+		if !inBlock && gooseEndsWithSemicolon(line) {
+			flush()
+		}
+	}
+
+	switch {
+	case state == start:
+		return nil, fmt.Errorf("failed to parse migration: must start with '-- +goose Up' annotation")
+	case inBlock:
+		return nil, fmt.Errorf("failed to parse migration: missing '-- +goose StatementEnd' annotation")
+	}
+	if remaining := strings.TrimSpace(buf.String()); remaining != "" {
+		return nil, fmt.Errorf("unexpected unfinished SQL query: %q: missing semicolon?", remaining)
+	}
+	return stmts, nil
+}
+
+// gooseEndsWithSemicolon 与 goose 相同，检查 line 中 -- 注释之前的最后一个单词是否以分号结尾
+func gooseEndsWithSemicolon(line string) bool {
+	prev := ""
+	for _, word := range strings.Fields(line) {
+		if strings.HasPrefix(word, "--") {
+			break
+		}
+		prev = word
+	}
+	return strings.HasSuffix(prev, ";")
+}
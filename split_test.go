@@ -1,6 +1,8 @@
 package goflyway
 
 import (
+	"errors"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
@@ -37,6 +39,16 @@ func TestMultipleStatements(t *testing.T) {
 	}
 }
 
+func TestSplitString(t *testing.T) {
+	for _, input := range []string{"", "SELECT 1; SELECT 2;", "DELIMITER //\nSELECT 1//\nDELIMITER ;\nSELECT 2;", test_text2} {
+		expected, expectedErr := Split(strings.NewReader(input))
+		result, err := SplitString(input)
+		if !reflect.DeepEqual(result, expected) || !reflect.DeepEqual(err, expectedErr) {
+			t.Errorf("SplitString(%q) = %v, %v, want %v, %v", input, result, err, expected, expectedErr)
+		}
+	}
+}
+
 func TestFunctionWithSemicolon(t *testing.T) {
 	input := `CREATE FUNCTION update() RETURNS void AS $$
               BEGIN
@@ -169,6 +181,63 @@ $function$ language plpgsql;
 	}
 }
 
+// TestSplitAnnotated 测试已经用 StatementBegin/StatementEnd 包围的语句作为一条完整的语句返回
+func TestSplitAnnotated(t *testing.T) {
+	input := `DROP FUNCTION IF EXISTS XXXX1;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION XXXX(TEXT)
+RETURNS VOID AS $function$
+BEGIN
+	XXXX;
+	YYYY;
+END;
+$function$ language plpgsql;
+-- +goose StatementEnd
+
+DROP FUNCTION IF EXISTS XXXX1; DROP FUNCTION IF EXISTS XXXX2;
+`
+	expected := []string{
+		`DROP FUNCTION IF EXISTS XXXX1;`,
+		`-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION XXXX(TEXT)
+RETURNS VOID AS $function$
+BEGIN
+	XXXX;
+	YYYY;
+END;
+$function$ language plpgsql;
+-- +goose StatementEnd`,
+		`DROP FUNCTION IF EXISTS XXXX1;`,
+		`DROP FUNCTION IF EXISTS XXXX2;`,
+	}
+	result, annotated, err := SplitAnnotated(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("SplitAnnotated() error = %v", err)
+	}
+
+	if len(result) != len(expected) || len(annotated) != len(expected) {
+		t.Fatalf("SplitAnnotated() = %q, %v, want %d statements", result, annotated, len(expected))
+	}
+	for idx := range result {
+		if strings.TrimSpace(result[idx]) != strings.TrimSpace(expected[idx]) {
+			t.Errorf("Expected: %v, Got: %v", strings.TrimSpace(expected[idx]), strings.TrimSpace(result[idx]))
+		}
+		if annotated[idx] != (idx == 1) {
+			t.Errorf("statement %d annotated = %v, want %v", idx, annotated[idx], idx == 1)
+		}
+	}
+
+	// 转换时不会再次包围已经包围的语句
+	converted, err := ConvertString(input)
+	if err != nil {
+		t.Fatalf("ConvertString() error = %v", err)
+	}
+	if n := strings.Count(converted, "-- +goose StatementBegin"); n != 1 {
+		t.Errorf("ConvertString() has %d StatementBegin, want 1:\n%s", n, converted)
+	}
+}
+
 func normalizeWhitespace(s string) string {
 	var result strings.Builder
 	inSpace := false
@@ -257,3 +326,544 @@ CREATE TABLE tpt_domains (
 --   FOREIGN KEY(id) REFERENCES tpt_objects(id)  on delete cascade 
 -- );
 `
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+// TestSplit_ReadError 测试读取失败时返回错误而不是退出进程
+func TestSplit_ReadError(t *testing.T) {
+	if _, _, err := SplitByDelimiter(errReader{}, "goose"); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("SplitByDelimiter() error = %v, want read error", err)
+	}
+	if _, err := Split(errReader{}); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("Split() error = %v, want read error", err)
+	}
+	if _, err := ConvertFlywayToGoose(errReader{}); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("ConvertFlywayToGoose() error = %v, want read error", err)
+	}
+}
+
+// TestSplit_LongLine 测试超过 bufio.Scanner 默认 64KB 限制的单行语句
+func TestSplit_LongLine(t *testing.T) {
+	values := strings.Repeat("(1, 'aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa'),", 10000)
+	long := "INSERT INTO t VALUES " + values + "(2, 'b');"
+	if len(long) < 256*1024 {
+		t.Fatalf("test line too short: %d", len(long))
+	}
+	input := "CREATE TABLE t (id INT, v TEXT);\n" + long + "\nSELECT 1;\n"
+
+	statements, err := SplitString(input)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(statements) != 3 || strings.TrimSpace(statements[1]) != long {
+		t.Errorf("Split() returned %d statements, long statement intact = %v", len(statements), len(statements) > 1 && strings.TrimSpace(statements[1]) == long)
+	}
+
+	result, err := ConvertString(input)
+	if err != nil {
+		t.Fatalf("ConvertString() error = %v", err)
+	}
+	if !strings.Contains(result, long) {
+		t.Error("ConvertString() lost the long statement")
+	}
+}
+
+// TestTokenizer_Comments 测试不同方言的注释处理
+func TestTokenizer_Comments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  TokenizerOptions
+		want  []Token
+	}{
+		{
+			name:  "默认与以前一样作为 TokenText",
+			input: "/* note */x-- note\n",
+			want:  []Token{{Type: TokenText, Value: "/* note */"}, {Type: TokenText, Value: "x"}, {Type: TokenText, Value: "-- note\n"}},
+		},
+		{
+			name:  "普通块注释",
+			input: "/* note */x",
+			opts:  TokenizerOptions{CommentTokens: true, ExecutableComments: true},
+			want:  []Token{{Type: TokenComment, Value: "/* note */"}, {Type: TokenText, Value: "x"}},
+		},
+		{
+			name:  "行注释",
+			input: "-- note\n",
+			opts:  TokenizerOptions{CommentTokens: true, ExecutableComments: true},
+			want:  []Token{{Type: TokenComment, Value: "-- note\n"}},
+		},
+		{
+			name:  "MySQL 版本条件注释",
+			input: "/*!40101 SET NAMES utf8; */;",
+			opts:  TokenizerOptions{CommentTokens: true, ExecutableComments: true},
+			want:  []Token{{Type: TokenText, Value: "/*!40101 SET NAMES utf8; */"}, {Type: TokenSemicolon, Value: ";"}},
+		},
+		{
+			name:  "MySQL 优化器提示",
+			input: "/*+ INDEX(t idx) */",
+			opts:  TokenizerOptions{CommentTokens: true, ExecutableComments: true},
+			want:  []Token{{Type: TokenText, Value: "/*+ INDEX(t idx) */"}},
+		},
+		{
+			name:  "不识别可执行注释",
+			input: "/*!40101 x */",
+			opts:  TokenizerOptions{CommentTokens: true},
+			want:  []Token{{Type: TokenComment, Value: "/*!40101 x */"}},
+		},
+		{
+			name:  "PostgreSQL 嵌套注释",
+			input: "/* a /* b; */ c; */;",
+			opts:  TokenizerOptions{CommentTokens: true, NestedComments: true},
+			want:  []Token{{Type: TokenComment, Value: "/* a /* b; */ c; */"}, {Type: TokenSemicolon, Value: ";"}},
+		},
+		{
+			name:  "不支持嵌套时在第一个结束符处结束",
+			input: "/* a /* b */;",
+			opts:  TokenizerOptions{CommentTokens: true},
+			want:  []Token{{Type: TokenComment, Value: "/* a /* b */"}, {Type: TokenSemicolon, Value: ";"}},
+		},
+		{
+			name:  "嵌套注释中的 */ 和 /* 不重叠",
+			input: "/*/ x */;",
+			opts:  TokenizerOptions{CommentTokens: true, NestedComments: true},
+			want:  []Token{{Type: TokenComment, Value: "/*/ x */"}, {Type: TokenSemicolon, Value: ";"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenizer := NewTokenizerWithOptions(strings.NewReader(tt.input), tt.opts)
+			var got []Token
+			for {
+				token, err := tokenizer.NextToken()
+				if token.Value != "" {
+					got = append(got, token)
+				}
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Fatalf("NextToken() error = %v", err)
+					}
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NextToken() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConfig_NestedComments 测试 Config.NestedComments 用于 SplitWithConfig 和转换
+func TestConfig_NestedComments(t *testing.T) {
+	input := "/* a /* b; */ c; */\nSELECT 1;\n"
+
+	statements, err := SplitWithConfig(strings.NewReader(input), &Config{})
+	if err != nil {
+		t.Fatalf("SplitWithConfig() error = %v", err)
+	}
+	if len(statements) != 2 {
+		t.Errorf("SplitWithConfig() without nested comments = %q, want 2 statements", statements)
+	}
+
+	cfg := &Config{NestedComments: true}
+	statements, err = SplitWithConfig(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("SplitWithConfig() error = %v", err)
+	}
+	if want := []string{"/* a /* b; */ c; */\nSELECT 1;"}; !reflect.DeepEqual(statements, want) {
+		t.Errorf("SplitWithConfig() = %q, want %q", statements, want)
+	}
+
+	result, err := ConvertFlywayToGooseWithConfig(strings.NewReader(input), cfg)
+	if err != nil {
+		t.Fatalf("ConvertFlywayToGooseWithConfig() error = %v", err)
+	}
+	up, err := parseGooseStatements(result, true)
+	if err != nil {
+		t.Fatalf("parseGooseStatements() error = %v", err)
+	}
+	if len(up) != 1 || !strings.Contains(up[0], "/* a /* b; */ c; */\nSELECT 1;") {
+		t.Errorf("up statements = %q, want the commented statement once", up)
+	}
+}
+
+// TestBacktickIdentifier 测试反引号括起的 MySQL 标识符
+func TestBacktickIdentifier(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "标识符中的分号",
+			input: "CREATE TABLE `a;b` (id INT); SELECT 1;",
+			want:  []string{"CREATE TABLE `a;b` (id INT);", " SELECT 1;"},
+		},
+		{
+			name:  "标识符中的 BEGIN",
+			input: "SELECT `begin` FROM t; SELECT 2;",
+			want:  []string{"SELECT `begin` FROM t;", " SELECT 2;"},
+		},
+		{
+			name:  "两个反引号转义",
+			input: "SELECT `a``;b` FROM t; SELECT 3;",
+			want:  []string{"SELECT `a``;b` FROM t;", " SELECT 3;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitString(tt.input)
+			if err != nil {
+				t.Fatalf("SplitString() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDollarQuotedLiteral 测试出现在任意位置的 dollar-quote 字符串
+func TestDollarQuotedLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "SELECT 中的 $$",
+			input: "SELECT $$text with ; semicolon$$; SELECT 2;",
+			want:  []string{"SELECT $$text with ; semicolon$$;", " SELECT 2;"},
+		},
+		{
+			name:  "INSERT 中带标签的 dollar-quote",
+			input: "INSERT INTO t VALUES ($body$a; BEGIN b$body$, 'c'); SELECT 2;",
+			want:  []string{"INSERT INTO t VALUES ($body$a; BEGIN b$body$, 'c');", " SELECT 2;"},
+		},
+		{
+			name:  "不同标签的 $$ 不会结束字符串",
+			input: "SELECT $x$a $$;$$ b$x$; SELECT 2;",
+			want:  []string{"SELECT $x$a $$;$$ b$x$;", " SELECT 2;"},
+		},
+		{
+			name:  "位置参数和标识符中的 $",
+			input: "SELECT a$b$ FROM t WHERE id = $1; SELECT 2;",
+			want:  []string{"SELECT a$b$ FROM t WHERE id = $1;", " SELECT 2;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitString(tt.input)
+			if err != nil {
+				t.Fatalf("SplitString() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNestedDollarQuotes 测试函数体中嵌套的不同标签的 dollar-quote
+func TestNestedDollarQuotes(t *testing.T) {
+	function := "CREATE FUNCTION f() RETURNS void AS $func$\n" +
+		"BEGIN\n" +
+		"  EXECUTE $body$ INSERT INTO t VALUES (1); END; $body$;\n" +
+		"  PERFORM $$ BEGIN $$;\n" +
+		"END;\n" +
+		"$func$ LANGUAGE plpgsql;"
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "AS 之后的块",
+			input: function + "\nSELECT 2;",
+			want:  []string{function, "\nSELECT 2;"},
+		},
+		{
+			name:  "DO 块",
+			input: "DO $do$ BEGIN PERFORM $x$;$x$; END $do$;\nSELECT 2;",
+			want:  []string{"DO $do$ BEGIN PERFORM $x$;$x$; END $do$;", "\nSELECT 2;"},
+		},
+		{
+			name:  "AS 之后不是 dollar-quote",
+			input: "SELECT 1 AS $1; SELECT $a$;$a$;",
+			want:  []string{"SELECT 1 AS $1;", " SELECT $a$;$a$;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitString(tt.input)
+			if err != nil {
+				t.Fatalf("SplitString() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBlockDepth_CaseAndIf 测试 CASE ... END 和 IF ... END IF 不会打乱 BEGIN/END 的深度
+func TestBlockDepth_CaseAndIf(t *testing.T) {
+	function := `CREATE PROCEDURE grade(IN score INT)
+BEGIN
+  DECLARE g CHAR(1);
+  SET g = CASE WHEN score > 90 THEN 'A' ELSE 'B' END;
+  IF score < 60 THEN
+    SET g = 'F';
+  END IF;
+  CASE g
+    WHEN 'F' THEN SELECT 'fail';
+    ELSE SELECT 'pass';
+  END CASE;
+  WHILE score > 0 DO
+    SET score = score - 10;
+  END WHILE;
+  SELECT g;
+END;`
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "过程中的 CASE 和 IF",
+			input: function + "\nSELECT 1;",
+			want:  []string{function, "\nSELECT 1;"},
+		},
+		{
+			name:  "语句中的 CASE 表达式",
+			input: "SELECT CASE WHEN a THEN 1 END FROM t; SELECT 2;",
+			want:  []string{"SELECT CASE WHEN a THEN 1 END FROM t;", " SELECT 2;"},
+		},
+		{
+			name:  "IF EXISTS 不影响深度",
+			input: "DROP TABLE IF EXISTS t; SELECT 2;",
+			want:  []string{"DROP TABLE IF EXISTS t;", " SELECT 2;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitString(tt.input)
+			if err != nil {
+				t.Fatalf("SplitString() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitDetailed 测试每条语句的行数和字节数
+func TestSplitDetailed(t *testing.T) {
+	input := "CREATE TABLE a (id INT);\nINSERT INTO a VALUES\n  (1),\n  (2),\n  (3);\n-- +goose StatementBegin\nSELECT 1;\nSELECT 2;\n-- +goose StatementEnd\n"
+	statements, err := SplitDetailed(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("SplitDetailed() error = %v", err)
+	}
+
+	expected := []struct {
+		lineCount int
+		byteSize  int
+		annotated bool
+	}{
+		{1, len("CREATE TABLE a (id INT);"), false},
+		{4, len("\nINSERT INTO a VALUES\n  (1),\n  (2),\n  (3);"), false},
+		{4, len("-- +goose StatementBegin\nSELECT 1;\nSELECT 2;\n-- +goose StatementEnd"), true},
+	}
+	if len(statements) != len(expected) {
+		t.Fatalf("SplitDetailed() = %d statements, want %d: %+v", len(statements), len(expected), statements)
+	}
+	for idx, stmt := range statements {
+		want := expected[idx]
+		if stmt.LineCount != want.lineCount || stmt.ByteSize != want.byteSize || stmt.Annotated != want.annotated {
+			t.Errorf("statement %d = {LineCount: %d, ByteSize: %d, Annotated: %v}, want {%d, %d, %v}\n%q",
+				idx, stmt.LineCount, stmt.ByteSize, stmt.Annotated, want.lineCount, want.byteSize, want.annotated, stmt.Text)
+		}
+	}
+}
+
+// TestSplitIter 测试逐条返回语句
+func TestSplitIter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "自定义分隔符",
+			input: "DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\nDELIMITER ;\nSELECT 2;",
+		},
+		{
+			name:  "BEGIN/END 块",
+			input: "CREATE FUNCTION f() BEGIN SELECT 1; SELECT 2; END; SELECT 3;",
+		},
+		{
+			name:  "StatementBegin/StatementEnd 块",
+			input: "SELECT 1;\n-- +goose StatementBegin\nSELECT 2; SELECT 3;\n-- +goose StatementEnd\nSELECT 4;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := SplitString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			err = SplitIter(strings.NewReader(tt.input), func(stmt string) bool {
+				got = append(got, stmt)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("SplitIter() error = %v", err)
+			}
+			if len(got) < 2 || !reflect.DeepEqual(got, want) {
+				t.Errorf("SplitIter() = %q, want %q", got, want)
+			}
+
+			// yield 返回 false 时停止
+			var first []string
+			err = SplitIter(strings.NewReader(tt.input), func(stmt string) bool {
+				first = append(first, stmt)
+				return false
+			})
+			if err != nil {
+				t.Fatalf("SplitIter() error = %v", err)
+			}
+			if !reflect.DeepEqual(first, want[:1]) {
+				t.Errorf("SplitIter() stopped with %q, want %q", first, want[:1])
+			}
+		})
+	}
+}
+
+func TestValidateGooseSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "正常",
+			content: "-- +goose Up\n-- +goose StatementBegin\nSELECT 1; SELECT 2;\n-- +goose StatementEnd\n-- +goose Down\nSELECT 3;\n",
+		},
+		{
+			name:    "缺少 StatementEnd",
+			content: "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n",
+			wantErr: "line 2: StatementBegin has no matching StatementEnd",
+		},
+		{
+			name:    "多余的 StatementEnd",
+			content: "-- +goose Up\nSELECT 1;\n-- +goose StatementEnd\n",
+			wantErr: "line 3: StatementEnd without StatementBegin",
+		},
+		{
+			name:    "块跨越 Down",
+			content: "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n-- +goose Down\n-- +goose StatementEnd\n",
+			wantErr: "line 4: StatementBegin at line 2 is not closed before Down",
+		},
+		{
+			name:    "缺少 Up",
+			content: "SELECT 1;\n",
+			wantErr: "missing -- +goose Up annotation",
+		},
+		{
+			name:    "最后一条语句缺少分号",
+			content: "-- +goose Up\nSELECT 1;\n-- +goose Down\nSELECT 2\n",
+			wantErr: `unexpected unfinished SQL query: "SELECT 2": missing semicolon?`,
+		},
+		{
+			name:    "Up 中的语句缺少分号",
+			content: "-- +goose Up\nSELECT 1\n-- +goose Down\nSELECT 2;\n",
+			wantErr: `unexpected unfinished SQL query: "SELECT 1": missing semicolon?`,
+		},
+		{
+			name:    "重复的 Up",
+			content: "-- +goose Up\nSELECT 1;\n-- +goose Up\nSELECT 2;\n",
+			wantErr: "duplicate '-- +goose Up' annotations",
+		},
+		{
+			name:    "Down 在 Up 之前",
+			content: "-- +goose Down\nSELECT 1;\n-- +goose Up\nSELECT 2;\n",
+			wantErr: "must start with '-- +goose Up' annotation",
+		},
+		{
+			name:    "Up 之前有 SQL",
+			content: "SELECT 1;\n-- +goose Up\nSELECT 2;\n",
+			wantErr: `failed to parse migration: unexpected line "SELECT 1;" before '-- +goose Up' annotation`,
+		},
+		{
+			name:    "未知的注解",
+			content: "-- +goose Up\n-- +goose NoTransaction\nSELECT 1;\n",
+			wantErr: `unknown annotation: "NoTransaction"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGooseSQL(tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateGooseSQL() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateGooseSQL() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseGooseStatements(t *testing.T) {
+	content := "-- +goose Up\n" +
+		"-- leading comment\n" +
+		"CREATE TABLE a (id INT); -- a;\n" +
+		"INSERT INTO a\n  VALUES (1);\n" +
+		"-- +goose StatementBegin\n" +
+		"CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\n" +
+		"-- +goose StatementEnd\n" +
+		"\n-- +goose Down\n" +
+		"DROP TABLE a;\n"
+
+	up, err := parseGooseStatements(content, true)
+	if err != nil {
+		t.Fatalf("parseGooseStatements() error = %v", err)
+	}
+	if len(up) != 3 || up[0] != "CREATE TABLE a (id INT); -- a;" || up[1] != "INSERT INTO a\n  VALUES (1);" || !strings.HasSuffix(up[2], "$$ LANGUAGE plpgsql;") {
+		t.Errorf("up = %q", up)
+	}
+	down, err := parseGooseStatements(content, false)
+	if err != nil {
+		t.Fatalf("parseGooseStatements() error = %v", err)
+	}
+	if !reflect.DeepEqual(down, []string{"DROP TABLE a;"}) {
+		t.Errorf("down = %q", down)
+	}
+
+	for _, invalid := range []string{
+		"CREATE TABLE a (id INT);\n",
+		"-- +goose Up\nCREATE TABLE a (id INT)\n",
+		"-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n",
+		"-- +goose Up\n-- +goose Up\n",
+		"-- +goose Up\n-- +goose Unknown\n",
+	} {
+		if _, err := parseGooseStatements(invalid, true); err == nil {
+			t.Errorf("parseGooseStatements(%q) expected error", invalid)
+		}
+	}
+}
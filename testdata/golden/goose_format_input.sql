@@ -0,0 +1,7 @@
+CREATE TABLE t (id INT);
+
+CREATE FUNCTION f() RETURNS INT AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE t (id INT);
+-- +goose StatementBegin
+CREATE FUNCTION f() RETURNS INT AS $$
+BEGIN
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+-- +goose Down
+-- Down migration is not supported in automatic conversion
@@ -0,0 +1,80 @@
+package goflyway
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerifyMismatch 表示一个 goose 解析出的语句数与 Split 不一致的 Flyway 文件
+type VerifyMismatch struct {
+	Source   string // Flyway 文件路径
+	Expected int    // Split 从原文件中分割出的语句数(不含空语句和只有注释的语句)
+	Actual   int    // goose 从转换后文件的 Up 部分解析出的语句数
+	Err      error  // goose 无法解析转换后的文件时的错误，此时 Actual 为 0
+}
+
+func (m VerifyMismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("%s: goose failed to parse the converted file: %v", m.Source, m.Err)
+	}
+	return fmt.Sprintf("%s: Split produced %d statements but goose parses %d", m.Source, m.Expected, m.Actual)
+}
+
+// Verify 按 cfg 转换 cfg.InputPath 中的迁移脚本(不写出任何文件)，检查每个文件转换后 goose 解析出的
+// 语句数与 Split 从原文件中分割出的语句数相同，返回不一致的文件。
+// 用于发现 Split 与 goose 对语句边界理解不同的情况(如 $$ 引用或自定义的分隔符)
+func Verify(cfg *Config) ([]VerifyMismatch, error) {
+	inputFS, closer, err := getInputFS(nil, cfg.InputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize input filesystem: %w", err)
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	var mismatches []VerifyMismatch
+	var splitErr error
+	p := newFSProcessor("", cfg)
+	p.sink = func(ConvertedFile) {}
+	p.inspect = func(path, text string, contents []string) {
+		if splitErr != nil {
+			return
+		}
+		expected, err := countStatements(text)
+		if err != nil {
+			splitErr = fmt.Errorf("failed to split %s: %w", path, err)
+			return
+		}
+		mismatch := VerifyMismatch{Source: path, Expected: expected}
+		for _, content := range contents {
+			stmts, err := parseGooseStatements(content, true)
+			if err != nil {
+				mismatch.Actual, mismatch.Err = 0, err
+				break
+			}
+			mismatch.Actual += len(stmts)
+		}
+		if mismatch.Err != nil || mismatch.Actual != mismatch.Expected {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	if _, err := p.run(inputFS); err != nil {
+		return nil, err
+	}
+	if splitErr != nil {
+		return nil, splitErr
+	}
+	return mismatches, nil
+}
+
+// countStatements 返回 Split 从 text 中分割出的语句数，空语句和只有注释的语句不计算在内
+func countStatements(text string) (int, error) {
+	count := 0
+	err := SplitIter(strings.NewReader(text), func(stmt string) bool {
+		if !isEmptyOrComments(stmt) {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
@@ -0,0 +1,56 @@
+package goflyway
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	inputs := map[string]string{
+		"V1__simple.sql":    "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1); INSERT INTO a VALUES (2);\n-- trailing\n",
+		"V2__function.sql":  "CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\n",
+		"V3__string.sql":    "INSERT INTO a VALUES ('x;\ny');\n",
+		"V4__delimiter.sql": "DELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; END//\nDELIMITER ;\nSELECT 2;\n",
+	}
+	dir := t.TempDir()
+	for name, content := range inputs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mismatches, err := Verify(&Config{InputPath: dir, BaseYear: "2000"})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("Verify() mismatches = %v, want none", mismatches)
+	}
+
+	// 钩子在语句后追加了一条语句，或者去掉了分号，goose 解析的结果与 Split 不一致
+	mismatches, err = Verify(&Config{InputPath: dir, BaseYear: "2000", SqlHandleHooks: []func(string) (string, error){
+		func(stmt string) (string, error) {
+			switch {
+			case strings.HasPrefix(stmt, "CREATE TABLE"):
+				return stmt + "\nSELECT 1;", nil
+			case strings.HasPrefix(stmt, "SELECT 2"):
+				return strings.TrimSuffix(stmt, ";"), nil
+			}
+			return stmt, nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("Verify() mismatches = %v, want 2", mismatches)
+	}
+	if m := mismatches[0]; m.Source != "V1__simple.sql" || m.Expected != 3 || m.Actual != 4 || m.Err != nil {
+		t.Errorf("mismatches[0] = %+v", m)
+	}
+	if m := mismatches[1]; m.Source != "V4__delimiter.sql" || m.Err == nil || !strings.Contains(m.String(), "goose failed to parse") {
+		t.Errorf("mismatches[1] = %+v", m)
+	}
+}
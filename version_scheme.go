@@ -0,0 +1,183 @@
+package goflyway
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionOverflow 表示版本号中某一段超出 VersionComponent.Max 时的处理方式
+type VersionOverflow string
+
+const (
+	// VersionOverflowError 返回错误
+	VersionOverflowError VersionOverflow = "error"
+	// VersionOverflowFold 超出的部分进位到前一段(如 Max 为 31 时 1.40 按 2.8 编码)，保持版本的先后顺序，
+	// 但可能与其它版本编码成同一个 goose 版本
+	VersionOverflowFold VersionOverflow = "fold"
+	// VersionOverflowClamp 超出时取 Max，小于 Min 时取 Min，可能与其它版本编码成同一个 goose 版本
+	VersionOverflowClamp VersionOverflow = "clamp"
+)
+
+// VersionComponent 描述 Flyway 版本号中的一段(以 '.' 分隔)以及它在 goose 版本号中的编码
+type VersionComponent struct {
+	Name    string // 用于错误信息，如 "major"
+	Digits  int    // 在 goose 版本号中占的十进制位数，不足时在前面补 0
+	Min     int    // 允许的最小值
+	Max     int    // 允许的最大值，必须小于 10^Digits
+	Default int    // 版本号中没有这一段时使用的值
+
+	// Unbounded 为 true 时这一段没有上限，忽略 Max，只能用于第一段。超过 Digits 位时 goose 版本号变长，
+	// 更长的数字总是更大，因此仍然按 Flyway 的顺序排列且不会重复
+	Unbounded bool
+}
+
+// rangeError 返回这一段的值不在允许范围内的错误
+func (c VersionComponent) rangeError() error {
+	if c.Unbounded {
+		return fmt.Errorf("%s version must be a number of at least %d", c.Name, c.Min)
+	}
+	return fmt.Errorf("%s version must be %d-%d", c.Name, c.Min, c.Max)
+}
+
+// VersionScheme 描述 Flyway 版本号如何解析以及如何编码为 goose 版本号：
+// goose 版本号为 baseYear 后依次拼接每一段按 Digits 补 0 后的数字。
+// 第一段必须存在，后面缺少的段使用 Default
+type VersionScheme struct {
+	Components []VersionComponent
+	Overflow   VersionOverflow // 为空时使用 VersionOverflowError
+}
+
+// DefaultVersionScheme 为默认的版本方案，将 x.y.z 编码为 baseYear + %02d%02d%06d，
+// x 小于 100 时与时间戳格式的 goose 版本号长度相同，x 没有上限，y 为 0-99，z 为 0-999999，超出时报错。
+// 为了与已经记录在 goose 版本表中的版本兼容，只有一段的版本 x 仍然按 x.1 编码，与 x.1 相同时作为重复的版本报错；
+// 多于 3 段的版本只有超出的段都为 0 时(如 1.2.3.0)才能编码，否则报错，这样的版本需要使用 OrderedVersionScheme
+var DefaultVersionScheme = VersionScheme{
+	Components: []VersionComponent{
+		{Name: "major", Digits: 2, Min: 0, Unbounded: true},
+		{Name: "minor", Digits: 2, Min: 0, Max: 99, Default: 1},
+		{Name: "patch", Digits: 6, Min: 0, Max: 999999},
+	},
+	Overflow: VersionOverflowError,
+}
+
+// OrderedVersionScheme 为按 Flyway 顺序编码的版本方案，将 w.x.y.z 编码为 baseYear + %03d%03d%05d%03d：
+// w 没有上限，x 为 0-999，y 为 0-99999，z 为 0-999，缺少的段与 Flyway 一样按 0 处理(1 与 1.0.0.0 相同)，
+// 多于 4 段的版本只有超出的段都为 0 时才能编码。Flyway 认为不同的版本编码后不会相同，大小顺序也与 Flyway 相同
+// (如 1 < 1.0.5 < 1.2.3.4 < 1.2.3.5 < 1.2.4)。
+// 它的编码与 DefaultVersionScheme 不同，已经用 DefaultVersionScheme 迁移过的数据库切换到它之前，
+// 需要先用 CopyOptions.VersionScheme 为它重新生成 goose 版本表，否则已经执行过的迁移会被当作未执行
+var OrderedVersionScheme = VersionScheme{
+	Components: []VersionComponent{
+		{Name: "major", Digits: 3, Min: 0, Unbounded: true},
+		{Name: "minor", Digits: 3, Min: 0, Max: 999},
+		{Name: "patch", Digits: 5, Min: 0, Max: 99999},
+		{Name: "build", Digits: 3, Min: 0, Max: 999},
+	},
+	Overflow: VersionOverflowError,
+}
+
+// versionScheme 返回 cfg.VersionScheme，为 nil 时返回 DefaultVersionScheme
+func (cfg *Config) versionScheme() *VersionScheme {
+	if cfg.VersionScheme == nil {
+		return &DefaultVersionScheme
+	}
+	return cfg.VersionScheme
+}
+
+// validate 检查方案本身是否有效
+func (s *VersionScheme) validate() error {
+	if len(s.Components) == 0 {
+		return fmt.Errorf("invalid version scheme: no components")
+	}
+	switch s.Overflow {
+	case "", VersionOverflowError, VersionOverflowFold, VersionOverflowClamp:
+	default:
+		return fmt.Errorf("invalid version scheme: unknown overflow policy %q", s.Overflow)
+	}
+	for i, c := range s.Components {
+		if c.Unbounded {
+			if i > 0 {
+				return fmt.Errorf("invalid version scheme: only the first component can be unbounded, not %s", c.Name)
+			}
+			if c.Digits < 1 || c.Min < 0 {
+				return fmt.Errorf("invalid version scheme: component %s does not fit in %d digits", c.Name, c.Digits)
+			}
+			continue
+		}
+		if c.Digits < 1 || c.Min < 0 || c.Min > c.Max || len(strconv.Itoa(c.Max)) > c.Digits {
+			return fmt.Errorf("invalid version scheme: component %s does not fit in %d digits", c.Name, c.Digits)
+		}
+	}
+	return nil
+}
+
+// parse 解析 Flyway 版本号，返回按方案规范化(补齐缺少的段、处理超出范围的段)之后每一段的值
+func (s *VersionScheme) parse(versionStr string) ([]int, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if versionStr == "" {
+		return nil, fmt.Errorf("missing version number")
+	}
+	// 与 Flyway 相同，版本号中的 '_' 等同于 '.'(V1_2_3 和 V1_2.3 都是 1.2.3)
+	parts := strings.Split(strings.ReplaceAll(versionStr, "_", "."), ".")
+	// 与 Flyway 一样，超出的段末尾为 0 时不影响版本的大小(1.2.3.0 与 1.2.3 相同)
+	for len(parts) > len(s.Components) {
+		if value, err := strconv.Atoi(parts[len(parts)-1]); err != nil || value != 0 {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > len(s.Components) {
+		return nil, fmt.Errorf("version format should have at most %d components", len(s.Components))
+	}
+
+	values := make([]int, len(s.Components))
+	for i, c := range s.Components {
+		if i >= len(parts) {
+			values[i] = c.Default
+			continue
+		}
+		value, err := strconv.Atoi(parts[i])
+		if err != nil || value < 0 {
+			return nil, c.rangeError()
+		}
+		values[i] = value
+	}
+
+	// 从最低的一段开始处理，Fold 时才能把进位加到前一段上
+	for i := len(parts) - 1; i >= 0; i-- {
+		c := s.Components[i]
+		if values[i] >= c.Min && (values[i] <= c.Max || c.Unbounded) {
+			continue
+		}
+		switch {
+		case s.Overflow == VersionOverflowClamp && values[i] < c.Min:
+			values[i] = c.Min
+		case s.Overflow == VersionOverflowClamp:
+			values[i] = c.Max
+		case s.Overflow == VersionOverflowFold && values[i] > c.Max && i > 0:
+			size := c.Max - c.Min + 1
+			values[i-1] += (values[i] - c.Min) / size
+			values[i] = c.Min + (values[i]-c.Min)%size
+		default:
+			return nil, c.rangeError()
+		}
+	}
+	return values, nil
+}
+
+// encode 将 parse 得到的每一段编码为 goose 版本号
+func (s *VersionScheme) encode(values []int, baseYear string) (string, error) {
+	var builder strings.Builder
+	builder.WriteString(baseYear)
+	for i, c := range s.Components {
+		builder.WriteString(fmt.Sprintf("%0*d", c.Digits, values[i]))
+	}
+	version := builder.String()
+	if _, err := strconv.ParseInt(version, 10, 64); err != nil {
+		return "", fmt.Errorf("goose version %s is out of range", version)
+	}
+	return version, nil
+}
@@ -0,0 +1,196 @@
+package goflyway
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+// TestVersionScheme 测试自定义的四段版本方案和 fold 处理方式
+func TestVersionScheme(t *testing.T) {
+	scheme := &VersionScheme{
+		Components: []VersionComponent{
+			{Name: "major", Digits: 2, Min: 0, Max: 99},
+			{Name: "minor", Digits: 2, Min: 0, Max: 99},
+			{Name: "patch", Digits: 2, Min: 0, Max: 9},
+			{Name: "build", Digits: 3, Min: 0, Max: 99},
+		},
+		Overflow: VersionOverflowFold,
+	}
+
+	tests := []struct {
+		name      string
+		version   string
+		expected  string
+		expectErr bool
+	}{
+		{"四段版本", "1.2.3.4", "2000010203004", false},
+		{"缺少的段使用默认值", "1.2", "2000010200000", false},
+		{"超出的段进位到前一段", "1.2.3.150", "2000010204050", false},
+		{"逐段进位", "1.2.12.100", "2000010303000", false},
+		{"最高段超出时报错", "100.1", "", true},
+		{"段数过多", "1.2.3.4.5", "", true},
+		{"不是数字", "1.x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertVersion(tt.version, "2000", VersionStrategySemantic, scheme)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("convertVersion() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if result != tt.expected {
+				t.Errorf("convertVersion() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	// 转换文件名时使用同一个方案
+	testFS := fstest.MapFS{
+		"V1.2.3.4__init.sql":   {Data: []byte("SELECT 1;\n")},
+		"V1.2.3.150__seed.sql": {Data: []byte("SELECT 2;\n")},
+	}
+	outputDir := t.TempDir()
+	if err := processFS(testFS, outputDir, &Config{BaseYear: "2000", VersionScheme: scheme}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{"2000010203004_init.sql", "2000010204050_seed.sql"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("output files = %v, want %v", names, expected)
+	}
+}
+
+// TestVersionScheme_Clamp 测试 clamp 处理方式和无效的方案
+func TestVersionScheme_Clamp(t *testing.T) {
+	scheme := DefaultVersionScheme
+	scheme.Overflow = VersionOverflowClamp
+	values, err := scheme.parse("15.140.0")
+	if err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	if expected := []int{15, 99, 0}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("parse() = %v, want %v", values, expected)
+	}
+
+	invalid := VersionScheme{Components: []VersionComponent{{Name: "major", Digits: 1, Max: 10}}}
+	if _, err := invalid.parse("1"); err == nil {
+		t.Error("expected error for a component that does not fit in its digits")
+	}
+}
+
+// TestDefaultVersionScheme_Order 测试默认方案编码后的顺序与 Flyway 相同且不会重复
+func TestDefaultVersionScheme_Order(t *testing.T) {
+	// 按 Flyway 的顺序排列，只有一段的版本 x 按 x.1 编码，因此不与 x.0.z 和 x.1 一起出现
+	versions := []string{"0", "0.2", "1", "1.2", "1.2.3", "1.31.999999", "1.45", "1.99.1", "9", "12.31", "13", "15", "99.99.999999", "100", "100.1.1", "1500", "12000.5"}
+
+	var previous int64
+	for _, version := range versions {
+		converted, err := convertVersion(version, "2000", VersionStrategySemantic, nil)
+		if err != nil {
+			t.Fatalf("convertVersion(%q) error = %v", version, err)
+		}
+		value, err := strconv.ParseInt(converted, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value <= previous {
+			t.Errorf("convertVersion(%q) = %d, not greater than the previous version %d", version, value, previous)
+		}
+		previous = value
+	}
+
+	// 超出的段不为 0 或超出范围时报错，需要使用 OrderedVersionScheme
+	for _, version := range []string{"1.2.3.4", "1.100"} {
+		if _, err := convertVersion(version, "2000", VersionStrategySemantic, nil); err == nil {
+			t.Errorf("convertVersion(%q) error = nil, want error", version)
+		}
+	}
+}
+
+// TestOrderedVersionScheme 测试多于 3 段的版本按 Flyway 的顺序编码
+func TestOrderedVersionScheme(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"1", "200000100000000000"},
+		{"1.2.3.4", "200000100200003004"},
+		{"1.2.3.4.0", "200000100200003004"},
+		{"1.2.3.5", "200000100200003005"},
+		{"1.2.4", "200000100200004000"},
+		{"1.100", "200000110000000000"},
+		{"1.999.99999.999", "200000199999999999"},
+	}
+	for _, tt := range tests {
+		result, err := convertVersion(tt.version, "2000", VersionStrategySemantic, &OrderedVersionScheme)
+		if err != nil {
+			t.Fatalf("convertVersion(%q) error = %v", tt.version, err)
+		}
+		if result != tt.expected {
+			t.Errorf("convertVersion(%q) = %v, want %v", tt.version, result, tt.expected)
+		}
+	}
+
+	// 打乱顺序并混合不同段数的版本，按 Flyway 的顺序排序后编码结果必须严格递增，Flyway 认为相同的版本编码也相同
+	versions := []string{"1.2.4", "1", "1.2.3.5", "1.1", "1.2.3.0", "0.2", "1.0.5", "999.999.99999.999", "1.2.3", "0",
+		"1.45", "1.100", "1000", "1.2.3.4", "1_2_5", "9", "1.99.1", "12.31", "1.0", "13", "100.1.1.1", "15", "1.0.0.1", "1.2"}
+	sort.Slice(versions, func(i, j int) bool { return compareFlywayVersions(versions[i], versions[j]) < 0 })
+
+	var previous int64
+	for i, version := range versions {
+		converted, err := convertVersion(version, "2000", VersionStrategySemantic, &OrderedVersionScheme)
+		if err != nil {
+			t.Fatalf("convertVersion(%q) error = %v", version, err)
+		}
+		value, err := strconv.ParseInt(converted, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && compareFlywayVersions(versions[i-1], version) == 0 {
+			if value != previous {
+				t.Errorf("convertVersion(%q) = %d, want %d like %q", version, value, previous, versions[i-1])
+			}
+		} else if value <= previous {
+			t.Errorf("convertVersion(%q) = %d, not greater than the previous version %s (%d)", version, value, versions[i-1], previous)
+		}
+		previous = value
+	}
+
+	if _, err := convertVersion("1.2.3.4.5", "2000", VersionStrategySemantic, &OrderedVersionScheme); err == nil {
+		t.Error("convertVersion(1.2.3.4.5) error = nil, want error")
+	}
+
+	// 转换文件名时使用同一个方案
+	testFS := fstest.MapFS{
+		"V1.2.4__c.sql":   {Data: []byte("SELECT 3;\n")},
+		"V1.2.3.4__a.sql": {Data: []byte("SELECT 1;\n")},
+		"V1.2.3.5__b.sql": {Data: []byte("SELECT 2;\n")},
+	}
+	outputDir := t.TempDir()
+	if err := processFS(testFS, outputDir, &Config{BaseYear: "2000", VersionScheme: &OrderedVersionScheme}); err != nil {
+		t.Fatalf("processFS() error = %v", err)
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{"200000100200003004_a.sql", "200000100200003005_b.sql", "200000100200004000_c.sql"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("output files = %v, want %v", names, expected)
+	}
+}